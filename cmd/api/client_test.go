@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+	"github.com/jim-at-jibba/greenlight/pkg/client"
+)
+
+// newClientServer serves the application's routes over HTTP and returns a
+// client for them, along with a count of the requests the server has seen
+func newClientServer(t *testing.T, app *application, options ...client.Option) (*client.Client, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+
+	routes := app.routes()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		routes.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(srv.URL, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, &requests
+}
+
+func TestClientValidationError(t *testing.T) {
+	app := newTestApplication(t)
+	c, _ := newClientServer(t, app)
+
+	_, err := c.RegisterUser(context.Background(), "", "not-an-email", "pa55")
+
+	var verr *client.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v; want a *client.ValidationError", err)
+	}
+
+	if verr.Err.StatusCode != http.StatusUnprocessableEntity || verr.Err.Code != client.CodeFailedValidation {
+		t.Errorf("got %+v", verr.Err)
+	}
+
+	want := map[string]string{
+		"name":     "required",
+		"email":    "format",
+		"password": "min_length",
+	}
+
+	for field, code := range want {
+		got := verr.Field(field)
+		if len(got) == 0 || got[0].Code != code || got[0].Message == "" {
+			t.Errorf("got %s errors %+v; want %q first", field, got, code)
+		}
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	newLimitedApp := func(t *testing.T) *application {
+		app := newTestApplication(t)
+		app.config.limiter.enabled = true
+		app.config.limiter.rps = 1
+		app.config.limiter.burst = 1
+
+		return app
+	}
+
+	t.Run("gives up", func(t *testing.T) {
+		c, _ := newClientServer(t, newLimitedApp(t), client.WithRetries(0, time.Hour, time.Hour))
+
+		// The first request uses up the burst
+		c.RegisterUser(context.Background(), "", "", "")
+
+		_, err := c.RegisterUser(context.Background(), "", "", "")
+		if !errors.Is(err, client.ErrRateLimitExceeded) {
+			t.Fatalf("got error %v; want ErrRateLimitExceeded", err)
+		}
+	})
+
+	t.Run("waits for Retry-After", func(t *testing.T) {
+		// The backoff is far longer than the test's deadline, so the retry
+		// only happens in time if the API's Retry-After is used instead
+		c, requests := newClientServer(t, newLimitedApp(t), client.WithRetries(1, time.Hour, time.Hour))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		c.RegisterUser(ctx, "", "", "")
+
+		start := time.Now()
+
+		_, err := c.RegisterUser(ctx, "", "", "")
+
+		var verr *client.ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("got error %v; want the retry to reach the handler", err)
+		}
+
+		if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+			t.Errorf("retried after %v; want it to wait for the limiter", elapsed)
+		}
+
+		if got := requests.Load(); got != 3 {
+			t.Errorf("got %d requests; want 3", got)
+		}
+	})
+}
+
+func TestClientAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+
+	db := testdb.New(t)
+	app.models = data.NewModels(db)
+
+	newTestUser(t, app, db)
+
+	c, _ := newClientServer(t, app)
+
+	input := client.MovieInput{
+		Title:   client.Ptr("Alien"),
+		Year:    client.Ptr(int32(1979)),
+		Runtime: client.Ptr(client.Runtime(117)),
+		Genres:  []string{"horror"},
+	}
+
+	assertCode := func(t *testing.T, err error, status int, code string) {
+		t.Helper()
+
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != status || apiErr.Code != code {
+			t.Errorf("got error %v; want %d %s", err, status, code)
+		}
+	}
+
+	_, err := c.CreateMovie(context.Background(), input)
+	assertCode(t, err, http.StatusUnauthorized, client.CodeAuthenticationRequired)
+
+	_, err = c.Authenticate(context.Background(), "alice@example.com", "wrong password")
+	assertCode(t, err, http.StatusUnauthorized, client.CodeInvalidCredentials)
+
+	token, err := c.Authenticate(context.Background(), "alice@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Token() != token.Token {
+		t.Errorf("got client token %q; want %q", c.Token(), token.Token)
+	}
+
+	// Authenticated but without movies:write
+	_, err = c.CreateMovie(context.Background(), input)
+	assertCode(t, err, http.StatusForbidden, client.CodeNotPermitted)
+
+	c.SetToken("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	_, err = c.GetMovie(context.Background(), 1)
+	assertCode(t, err, http.StatusUnauthorized, client.CodeInvalidAuthenticationToken)
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder is what gzip, zstd and brotli writers have in common, Reset lets
+// one be reused from a pool for the next response
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// contentEncodings are the encodings responses can be compressed with, in
+// order of preference for clients that don't mind which they get
+var contentEncodings = []string{"zstd", "br", "gzip"}
+
+var encoderPools = map[string]*sync.Pool{
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	"br": {New: func() any {
+		// Level 5 is close to gzip's speed while still compressing better
+		return brotli.NewWriterLevel(nil, 5)
+	}},
+	"gzip": {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+}
+
+// uncompressibleTypes are already compressed, or in the case of event
+// streams have to reach the client as soon as they are written
+var uncompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/zstd", "application/x-brotli",
+	"application/pdf", "application/octet-stream", "text/event-stream",
+}
+
+// compress compresses responses with the best encoding in the client's
+// Accept-Encoding header. Responses are buffered until they reach
+// cfg.compress.minBytes, anything smaller goes out as it is because the
+// encoding headers would cost more than they save
+func (app *application) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if !app.config.compress.enabled || encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minBytes:       app.config.compress.minBytes,
+			status:         http.StatusOK,
+		}
+
+		defer func() {
+			err := cw.Close()
+			if err != nil {
+				app.logError(r, err)
+			}
+		}()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks an encoding from an Accept-Encoding header, or ""
+// if the response shouldn't be compressed
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+
+	for _, encoding := range contentEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter holds on to the status and the start of the body until it
+// knows whether the response is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minBytes int
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+
+	// Informational responses such as 103 Early Hints go straight out
+	if status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+
+		if len(cw.buf) < cw.minBytes {
+			return len(p), nil
+		}
+
+		if err := cw.start(); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// start sends the headers and whatever has been buffered, compressed if the
+// response is big enough and of a type that's worth compressing
+func (cw *compressWriter) start() error {
+	cw.decided = true
+
+	h := cw.Header()
+
+	if len(cw.buf) >= cw.minBytes && compressible(cw.status, h) {
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+
+		// A strong ETag would claim the compressed bytes match the
+		// uncompressed ones
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+func compressible(status int, h http.Header) bool {
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+
+	for _, prefix := range uncompressibleTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Flush sends what has been written so far, even if it's less than
+// minBytes, so handlers that stream still work
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.start(); err != nil {
+			return
+		}
+	}
+
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the response and returns the encoder to its pool
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		// Short responses are never compressed
+		cw.minBytes = len(cw.buf) + 1
+
+		if err := cw.start(); err != nil {
+			return err
+		}
+	}
+
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+
+	cw.enc.Reset(nil)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+var errUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+// decoder is a pooled reader for a compressed request body
+type decoder interface {
+	io.Reader
+	Reset(r io.Reader) error
+}
+
+var decoderPools = map[string]*sync.Pool{
+	"zstd": {New: func() any {
+		dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		return dec
+	}},
+	"br": {New: func() any {
+		return brotli.NewReader(nil)
+	}},
+	"gzip": {New: func() any {
+		return new(gzip.Reader)
+	}},
+}
+
+// decompressBody returns a reader for the decoded request body and a
+// function that hands the decoder back to its pool once the body has been
+// read. Bodies without a Content-Encoding are returned as they are
+func decompressBody(r *http.Request) (io.Reader, func(), error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+
+	switch encoding {
+	case "", "identity":
+		return r.Body, func() {}, nil
+	case "x-gzip":
+		encoding = "gzip"
+	}
+
+	pool, ok := decoderPools[encoding]
+	if !ok {
+		return nil, nil, errUnsupportedContentEncoding
+	}
+
+	dec := pool.Get().(decoder)
+
+	if err := dec.Reset(r.Body); err != nil {
+		pool.Put(dec)
+
+		// An empty body can't even start a gzip stream
+		if errors.Is(err, io.EOF) {
+			return nil, nil, newRequestError("empty", nil, "body must not be empty")
+		}
+
+		return nil, nil, newRequestError("body_malformed", map[string]any{"format": encoding}, "body contains badly-formed %s", encoding)
+	}
+
+	return &malformedReader{r: dec, format: encoding}, func() { pool.Put(dec) }, nil
+}
+
+// malformedReader reports corrupt compressed data as a client error rather
+// than a server one
+type malformedReader struct {
+	r      io.Reader
+	format string
+}
+
+func (m *malformedReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+
+	var maxBytesError *http.MaxBytesError
+	if err == nil || err == io.EOF || errors.As(err, &maxBytesError) {
+		return n, err
+	}
+
+	return n, newRequestError("body_malformed", map[string]any{"format": m.format}, "body contains badly-formed %s", m.format)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip, br, zstd", "zstd"},
+		{"GZIP", "gzip"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"zstd;q=0, *", "br"},
+		{"*;q=0", ""},
+		{"deflate", ""},
+		{"identity", ""},
+		{"gzip;q=nonsense, br", "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := negotiateEncoding(tt.header); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		want    bool
+	}{
+		{"json", http.StatusOK, map[string]string{"Content-Type": "application/json"}, true},
+		{"no content type", http.StatusOK, nil, true},
+		{"no content", http.StatusNoContent, nil, false},
+		{"not modified", http.StatusNotModified, nil, false},
+		{"partial content", http.StatusPartialContent, map[string]string{"Content-Type": "application/json"}, false},
+		{"already encoded", http.StatusOK, map[string]string{"Content-Encoding": "gzip"}, false},
+		{"range", http.StatusOK, map[string]string{"Content-Range": "bytes 0-99/1000"}, false},
+		{"image", http.StatusOK, map[string]string{"Content-Type": "image/jpeg"}, false},
+		{"event stream", http.StatusOK, map[string]string{"Content-Type": "text/event-stream; charset=utf-8"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for key, value := range tt.headers {
+				h.Set(key, value)
+			}
+
+			if got := compressible(tt.status, h); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"title": "Alien", "year": 1979}`, 100)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		etag           string
+		body           string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", body: large, wantEncoding: "gzip"},
+		{name: "brotli", acceptEncoding: "gzip, br", body: large, wantEncoding: "br"},
+		{name: "zstd", acceptEncoding: "gzip, br, zstd", body: large, wantEncoding: "zstd"},
+		{name: "not asked for", body: large},
+		{name: "too small", acceptEncoding: "gzip", body: `{"title": "Alien"}`},
+		{name: "head", method: http.MethodHead, acceptEncoding: "gzip", body: large},
+		{name: "image", acceptEncoding: "gzip", contentType: "image/png", body: large},
+		{name: "strong etag", acceptEncoding: "gzip", etag: `"abc"`, body: large, wantEncoding: "gzip", wantETag: `W/"abc"`},
+		{name: "weak etag", acceptEncoding: "gzip", etag: `W/"abc"`, body: large, wantEncoding: "gzip", wantETag: `W/"abc"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.compress.enabled = true
+			app.config.compress.minBytes = 1024
+
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			handler := app.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+
+				// Written in pieces, so the first few are buffered
+				for i := 0; i < len(tt.body); i += 100 {
+					w.Write([]byte(tt.body[i:min(i+100, len(tt.body))]))
+				}
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			r := httptest.NewRequest(method, "/", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("got Content-Encoding %q; want %q", got, tt.wantEncoding)
+			}
+
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("got Vary %q; want Accept-Encoding", got)
+			}
+
+			if tt.wantETag != "" && w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("got ETag %q; want %q", w.Header().Get("ETag"), tt.wantETag)
+			}
+
+			var body io.Reader = w.Body
+			if tt.wantEncoding != "" {
+				var err error
+
+				body, err = decoders[tt.wantEncoding](w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.body {
+				t.Errorf("got %d bytes of body; want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestDecompressBody(t *testing.T) {
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(s))
+		w.Close()
+
+		return buf.String()
+	}
+
+	invalid := `{"name": "", "email": "alice@example.com", "password": "pa55word"}`
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     string
+		status   int
+		message  string
+	}{
+		{"gzip", "gzip", gzipped(invalid), http.StatusUnprocessableEntity, "must be provided"},
+		{"x-gzip", "x-gzip", gzipped(invalid), http.StatusUnprocessableEntity, "must be provided"},
+		{"identity", "identity", invalid, http.StatusUnprocessableEntity, "must be provided"},
+		// A few kilobytes that decompress to more than the 1MB limit
+		{
+			"too large once decompressed", "gzip",
+			gzipped(`{"name": "` + strings.Repeat("a", 2_000_000) + `"}`),
+			http.StatusBadRequest, "body must not be larger than 1048576 bytes",
+		},
+		{"malformed", "gzip", "not gzip at all", http.StatusBadRequest, "body contains badly-formed gzip"},
+		{"truncated", "gzip", gzipped(invalid)[:20], http.StatusBadRequest, "body contains badly-formed gzip"},
+		{"empty", "gzip", "", http.StatusBadRequest, "body must not be empty"},
+		{"unsupported", "compress", invalid, http.StatusUnsupportedMediaType, "compress"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Content-Encoding", tt.encoding)
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("got status %d; want %d", w.Code, tt.status)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("got %s; want %q", w.Body, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Every format is produced from the JSON encoding of the envelope, so the
+// json tags, omitempty and custom MarshalJSON methods such as data.Runtime's
+// decide what clients see whichever format they ask for. Request bodies go
+// the other way: they are converted to JSON and decoded with decodeJSON, so
+// unknown keys and type errors are reported the same way for every format
+type format struct {
+	mediaType string
+	// Other media types clients use for the same format
+	aliases []string
+	// Only offered for responses suits returns true for, e.g. CSV needs the
+	// envelope to hold a single list. nil suits every response
+	suits  func(js []byte) bool
+	encode func(js []byte) ([]byte, error)
+	decode func(body []byte, dst any) ([]byte, error)
+}
+
+var (
+	jsonFormat = &format{
+		mediaType: "application/json",
+		encode:    func(js []byte) ([]byte, error) { return js, nil },
+		decode:    func(body []byte, _ any) ([]byte, error) { return body, nil },
+	}
+	xmlFormat = &format{
+		mediaType: "application/xml",
+		aliases:   []string{"text/xml"},
+		encode:    encodeXML,
+		decode:    decodeXML,
+	}
+	csvFormat = &format{
+		mediaType: "text/csv",
+		suits:     func(js []byte) bool { return csvList(js) != nil },
+		encode:    encodeCSV,
+	}
+	msgpackFormat = &format{
+		mediaType: "application/msgpack",
+		aliases:   []string{"application/x-msgpack", "application/vnd.msgpack"},
+		encode:    encodeMsgpack,
+		decode:    decodeMsgpack,
+	}
+	cborFormat = &format{
+		mediaType: "application/cbor",
+		encode:    encodeCBOR,
+		decode:    decodeCBOR,
+	}
+	jsonAPIFormat = &format{
+		mediaType: "application/vnd.api+json",
+		suits:     jsonAPIResources,
+		encode:    encodeJSONAPI,
+		decode:    decodeJSONAPI,
+	}
+)
+
+// formats is in order of preference, JSON wins when the client doesn't mind
+var formats = []*format{jsonFormat, xmlFormat, csvFormat, msgpackFormat, cborFormat, jsonAPIFormat}
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges in the Accept header, skipping any
+// that don't parse. A missing header is the same as */*
+func parseAccept(r *http.Request) []mediaRange {
+	var ranges []mediaRange
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, value := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(value)
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(value, 64)
+				if err != nil {
+					continue
+				}
+			}
+
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+
+	if len(ranges) == 0 {
+		ranges = []mediaRange{{mediaType: "*/*", q: 1}}
+	}
+
+	return ranges
+}
+
+// quality is how much the client wants f, taken from the most specific
+// range that matches it
+func (f *format) quality(ranges []mediaRange) float64 {
+	q, specificity := 0.0, -1
+
+	for _, mr := range ranges {
+		s := -1
+
+		switch {
+		case mr.mediaType == f.mediaType || contains(f.aliases, mr.mediaType):
+			s = 2
+		case strings.HasSuffix(mr.mediaType, "/*") && strings.HasPrefix(f.mediaType, strings.TrimSuffix(mr.mediaType, "*")):
+			s = 1
+		case mr.mediaType == "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+
+	return q
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiate picks the format for a response from the Accept header, or nil
+// when the client won't take any of the formats that suit js
+func negotiate(r *http.Request, js []byte) *format {
+	return negotiateFormat(r, func(f *format) bool { return f.suits == nil || f.suits(js) })
+}
+
+// negotiateFormat is negotiate for callers that haven't encoded the response
+// yet. suits is only called for formats the client would prefer to the best
+// one so far
+func negotiateFormat(r *http.Request, suits func(f *format) bool) *format {
+	ranges := parseAccept(r)
+
+	var best *format
+	bestQ := 0.0
+
+	for _, f := range formats {
+		q := f.quality(ranges)
+		if q <= bestQ || !suits(f) {
+			continue
+		}
+
+		best, bestQ = f, q
+	}
+
+	return best
+}
+
+// decodeGeneric turns JSON into maps, slices and scalars, keeping numbers
+// as json.Number so they aren't rounded through float64
+func decodeGeneric(js []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+
+	return v, err
+}
+
+// binaryValue swaps json.Numbers for int64 or float64 so the binary formats
+// write real numbers rather than strings
+func binaryValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = binaryValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = binaryValue(value)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	}
+
+	return v
+}
+
+func encodeMsgpack(js []byte) ([]byte, error) {
+	v, err := decodeGeneric(js)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+
+	err = enc.Encode(binaryValue(v))
+
+	return buf.Bytes(), err
+}
+
+func decodeMsgpack(body []byte, _ any) ([]byte, error) {
+	var v any
+
+	err := msgpack.Unmarshal(body, &v)
+	if err != nil {
+		return nil, newRequestError("body_malformed", map[string]any{"format": "MessagePack"}, "body contains badly-formed MessagePack")
+	}
+
+	return json.Marshal(v)
+}
+
+var (
+	cborEncMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+	cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any(nil))}.DecMode()
+)
+
+func encodeCBOR(js []byte) ([]byte, error) {
+	v, err := decodeGeneric(js)
+	if err != nil {
+		return nil, err
+	}
+
+	return cborEncMode.Marshal(binaryValue(v))
+}
+
+func decodeCBOR(body []byte, _ any) ([]byte, error) {
+	var v any
+
+	err := cborDecMode.Unmarshal(body, &v)
+	if err != nil {
+		return nil, newRequestError("body_malformed", map[string]any{"format": "CBOR"}, "body contains badly-formed CBOR")
+	}
+
+	return json.Marshal(v)
+}
+
+// csvList returns the rows of the only list in the envelope, e.g. "movies"
+// in {"movies": [...], "metadata": {...}}, or nil if there isn't exactly one
+// list of objects
+func csvList(js []byte) []any {
+	v, err := decodeGeneric(js)
+	if err != nil {
+		return nil
+	}
+
+	env, _ := v.(map[string]any)
+
+	var rows []any
+	lists := 0
+
+	for _, value := range env {
+		if list, ok := value.([]any); ok {
+			rows = list
+			lists++
+		}
+	}
+
+	if lists != 1 {
+		return nil
+	}
+
+	for _, row := range rows {
+		if _, ok := row.(map[string]any); !ok {
+			return nil
+		}
+	}
+
+	if rows == nil {
+		rows = []any{}
+	}
+
+	return rows
+}
+
+// encodeCSV writes one row per item in the envelope's list with a header of
+// every key used by any of them. Lists of plain values are joined with ";",
+// anything more complicated is written as JSON. Everything outside the list,
+// such as the pagination metadata, is left out
+func encodeCSV(js []byte) ([]byte, error) {
+	rows := csvList(js)
+
+	seen := make(map[string]bool)
+	var columns []string
+
+	for _, row := range rows {
+		for key := range row.(map[string]any) {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+
+	sort.Strings(columns)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if len(columns) > 0 {
+		w.Write(columns)
+	}
+
+	for _, row := range rows {
+		object := row.(map[string]any)
+
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = csvCell(object[column])
+		}
+
+		w.Write(record)
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+func csvCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		values := make([]string, len(v))
+
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				js, _ := json.Marshal(v)
+				return string(js)
+			default:
+				values[i] = csvCell(item)
+			}
+		}
+
+		return strings.Join(values, ";")
+	case map[string]any:
+		js, _ := json.Marshal(v)
+		return string(js)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// XML names can't contain the brackets in keys such as "genres[0]", those
+// are written as <entry key="genres[0]">
+var xmlNameRX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// encodeXML writes the envelope inside a <response> element. Object keys
+// become elements in sorted order and list items are <item> elements
+func encodeXML(js []byte) ([]byte, error) {
+	v, err := decodeGeneric(js)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+
+	if err := writeXMLElement(enc, "response", v); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+func writeXMLElement(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	if !xmlNameRX.MatchString(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := writeXMLElement(enc, key, v[key]); err != nil {
+				return err
+			}
+		}
+
+		return enc.EncodeToken(start.End())
+	case []any:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+
+		for _, item := range v {
+			if err := writeXMLElement(enc, "item", item); err != nil {
+				return err
+			}
+		}
+
+		return enc.EncodeToken(start.End())
+	case nil:
+		return enc.EncodeElement("", start)
+	default:
+		return enc.EncodeElement(fmt.Sprint(v), start)
+	}
+}
+
+type xmlNode struct {
+	name     string
+	text     string
+	children []*xmlNode
+}
+
+// decodeXML reads the layout encodeXML writes. XML has no types, so the
+// fields of dst decide whether an element is a number, a list or an object
+func decodeXML(body []byte, dst any) ([]byte, error) {
+	malformed := newRequestError("body_malformed", map[string]any{"format": "XML"}, "body contains badly-formed XML")
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	var stack []*xmlNode
+	var root *xmlNode
+
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, malformed
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: token.Name.Local}
+			for _, attr := range token.Attr {
+				if token.Name.Local == "entry" && attr.Name.Local == "key" {
+					node.name = attr.Value
+				}
+			}
+
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			} else {
+				return nil, malformed
+			}
+
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, newRequestError("empty", nil, "body must not be empty")
+	}
+
+	return json.Marshal(xmlValue(root, reflect.TypeOf(dst)))
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func xmlValue(node *xmlNode, t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		if t.Implements(jsonUnmarshalerType) {
+			break
+		}
+
+		t = t.Elem()
+	}
+
+	text := strings.TrimSpace(node.text)
+
+	if len(node.children) == 0 && text == "" {
+		return nil
+	}
+
+	// Types with their own UnmarshalJSON, such as data.Runtime, are given
+	// the text as a string to parse however they like
+	if t == nil || t.Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return text
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(text)
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	case reflect.Slice, reflect.Array:
+		items := make([]any, len(node.children))
+		for i, child := range node.children {
+			items[i] = xmlValue(child, t.Elem())
+		}
+
+		return items
+	case reflect.Map:
+		object := make(map[string]any, len(node.children))
+		for _, child := range node.children {
+			object[child.name] = xmlValue(child, t.Elem())
+		}
+
+		return object
+	case reflect.Struct:
+		object := make(map[string]any, len(node.children))
+		for _, child := range node.children {
+			object[child.name] = xmlValue(child, jsonFieldType(t, child.name))
+		}
+
+		return object
+	}
+
+	// Left as text, decodeJSON reports the type mismatch
+	return text
+}
+
+// jsonFieldType is the type of the field that decodes the JSON key name, or
+// nil when there isn't one
+func jsonFieldType(t reflect.Type, name string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		switch {
+		case field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct:
+			if ft := jsonFieldType(field.Type, name); ft != nil {
+				return ft
+			}
+		case tag == name, tag == "" && strings.EqualFold(field.Name, name):
+			return field.Type
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+func TestNegotiate(t *testing.T) {
+	list := []byte(`{"movies": [{"id": 1}], "metadata": {}}`)
+	single := []byte(`{"movie": {"id": 1}}`)
+
+	tests := []struct {
+		name   string
+		accept string
+		js     []byte
+		want   *format
+	}{
+		{"no header", "", single, jsonFormat},
+		{"anything", "*/*", single, jsonFormat},
+		{"xml", "application/xml", single, xmlFormat},
+		{"xml alias", "text/xml", single, xmlFormat},
+		{"csv list", "text/csv", list, csvFormat},
+		{"csv single", "text/csv", single, nil},
+		{"type wildcard", "text/*", list, csvFormat},
+		{"quality", "application/msgpack;q=0.5, application/cbor", single, cborFormat},
+		{"refused json", "application/json;q=0, */*", single, xmlFormat},
+		{"nothing acceptable", "image/png", single, nil},
+		{"unparseable", "not a media type", single, jsonFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			got := negotiate(r, tt.js)
+			if got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinaryFormatsRoundTrip(t *testing.T) {
+	// 2^53 + 1 can't be held by a float64, so this fails if it's rounded
+	js := []byte(`{"movie": {"id": 9007199254740993, "title": "Alien", "rating": 8.5, "genres": ["horror", "science-fiction"], "poster": null, "released": true}}`)
+
+	tests := []struct {
+		name string
+		f    *format
+	}{
+		{"msgpack", msgpackFormat},
+		{"cbor", cborFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tt.f.encode(js)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := tt.f.decode(body, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, _ := decodeGeneric(js)
+			have, err := decodeGeneric(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("got %s; want %s", got, js)
+			}
+		})
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	type input struct {
+		Title   *string       `json:"title"`
+		Year    *int32        `json:"year"`
+		Runtime *data.Runtime `json:"runtime"`
+		Genres  []string      `json:"genres"`
+	}
+
+	tests := []struct {
+		name string
+		js   string
+	}{
+		{"every field", `{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["horror", "science-fiction"]}`},
+		{"one genre", `{"title": "Alien", "genres": ["horror"]}`},
+		{"escaped", `{"title": "Alien <Director's Cut> & more"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := encodeXML([]byte(tt.js))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decodeXML(body, &input{})
+			if err != nil {
+				t.Fatalf("decoding %s: %v", body, err)
+			}
+
+			var have, want input
+
+			err = decodeJSON(bytes.NewReader(got), &have)
+			if err != nil {
+				t.Fatalf("decoding %s: %v", got, err)
+			}
+
+			decodeJSON(strings.NewReader(tt.js), &want)
+
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("got %s from %s", got, body)
+			}
+		})
+	}
+}
+
+func TestEncodeXMLNames(t *testing.T) {
+	got, err := encodeXML([]byte(`{"error": {"genres[0]": "must be provided", "title": "must be provided"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"<response>",
+		`<entry key="genres[0]">must be provided</entry>`,
+		"<title>must be provided</title>",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("got %s; want it to contain %s", got, want)
+		}
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		js   string
+		want string
+	}{
+		{
+			name: "columns sorted",
+			js:   `{"movies": [{"title": "Alien", "id": 1, "year": 1979}], "metadata": {"total_records": 1}}`,
+			want: "id,title,year\n1,Alien,1979\n",
+		},
+		{
+			name: "missing keys",
+			js:   `{"movies": [{"id": 1, "title": "Alien"}, {"id": 2, "year": 1986}]}`,
+			want: "id,title,year\n1,Alien,\n2,,1986\n",
+		},
+		{
+			name: "lists and objects",
+			js:   `{"movies": [{"genres": ["horror", "science-fiction"], "poster": {"width": 300}}]}`,
+			want: "genres,poster\nhorror;science-fiction,\"{\"\"width\"\":300}\"\n",
+		},
+		{
+			name: "empty",
+			js:   `{"movies": []}`,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeCSV([]byte(tt.js))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		f      *format
+		body   string
+		format string
+	}{
+		{"xml", xmlFormat, "<title>Alien", "XML"},
+		{"xml two roots", xmlFormat, "<a></a><b></b>", "XML"},
+		{"msgpack", msgpackFormat, "\xc1", "MessagePack"},
+		{"cbor", cborFormat, "\xff", "CBOR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.f.decode([]byte(tt.body), &struct{}{})
+
+			var requestErr *requestError
+			if !errors.As(err, &requestErr) || requestErr.key != "body_malformed" || requestErr.params["format"] != tt.format {
+				t.Errorf("got error %v; want a body_malformed error for %s", err, tt.format)
+			}
+		})
+	}
+}
+
+func TestResponseFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"json", "application/json", http.StatusOK, "application/json"},
+		{"xml", "application/xml", http.StatusOK, "application/xml"},
+		{"msgpack", "application/msgpack", http.StatusOK, "application/msgpack"},
+		// The healthcheck has no list to write rows for
+		{"csv", "text/csv", http.StatusNotAcceptable, "application/json"},
+		{"nothing acceptable", "image/png", http.StatusNotAcceptable, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("got status %d; want %d", w.Code, tt.status)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("got Content-Type %q; want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestRequestFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		message     string
+	}{
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body:        "<response><name></name><email>alice</email><password>pa55word</password></response>",
+			status:      http.StatusUnprocessableEntity,
+			message:     "must be a valid email address",
+		},
+		{
+			name:        "xml alias",
+			contentType: "text/xml; charset=utf-8",
+			body:        "<response><name></name></response>",
+			status:      http.StatusUnprocessableEntity,
+			message:     "must be provided",
+		},
+		{
+			name:        "malformed msgpack",
+			contentType: "application/msgpack",
+			body:        "\xc1",
+			status:      http.StatusBadRequest,
+			message:     "body contains badly-formed MessagePack",
+		},
+		{
+			name:        "unsupported",
+			contentType: "text/plain",
+			body:        "name=alice",
+			status:      http.StatusUnsupportedMediaType,
+			message:     "the text/plain content type is not supported",
+		},
+		{
+			name:        "response only",
+			contentType: "text/csv",
+			body:        "name\nalice\n",
+			status:      http.StatusUnsupportedMediaType,
+			message:     "the text/csv content type is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("got status %d; want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("got %s; want %q", w.Body, tt.message)
+			}
+		})
+	}
+}
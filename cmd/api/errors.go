@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/jim-at-jibba/greenlight/internal/i18n"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -12,50 +17,217 @@ func (app *application) logError(r *http.Request, err error) {
 	})
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
+// errorResponse writes {"error": message}, or an RFC 9457 problem details
+// object when the client's Accept header asks for application/problem+json.
+// code is a stable identifier for the kind of error, clients should match on
+// it rather than on the message. A validator message holds field errors, the
+// flattened messages go in "error" as they always have with every coded
+// error alongside in "fields", or in the problem's errors member.
+//
+// Messages are in the language picked from Accept-Language, string messages
+// should already have been localised with app.translate
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code string, message any) {
+	l := app.localizer(r)
+
+	if v, ok := message.(*validator.Validator); ok {
+		message = translateValidator(l, v)
+	}
+
 	env := envelope{"error": message}
 
-	err := app.writeJSON(w, status, env, nil)
+	if v, ok := message.(*validator.Validator); ok {
+		env = envelope{"error": v.Errors, "fields": v.Fields}
+	}
+
+	w.Header().Set("Content-Language", l.Tag().String())
+	w.Header().Add("Vary", "Accept-Language")
+
+	var headers http.Header
+
+	if app.wantsProblemJSON(r) {
+		env = envelope{
+			"type":     "urn:greenlight:problem:" + code,
+			"title":    http.StatusText(status),
+			"status":   status,
+			"instance": r.URL.Path,
+			"code":     code,
+		}
+
+		switch message := message.(type) {
+		case string:
+			env["detail"] = message
+		case *validator.Validator:
+			env["detail"] = l.Message([]string{"error.failed_validation"}, nil, "one or more fields failed validation")
+			env["errors"] = message.Fields
+		}
+
+		headers = make(http.Header)
+		headers.Set("Content-Type", "application/problem+json")
+	}
+
+	err := app.writeError(w, r, status, env, headers)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
 	}
 }
 
+// writeError is writeResponse for errors. They fall back to JSON rather than
+// a 406 when the client won't take any format, and problem details are
+// always JSON
+func (app *application) writeError(w http.ResponseWriter, r *http.Request, status int, env envelope, headers http.Header) error {
+	js, err := app.marshalJSON(r, env)
+	if err != nil {
+		return err
+	}
+
+	f := negotiate(r, js)
+	if f == nil || headers.Get("Content-Type") != "" {
+		f = jsonFormat
+	}
+
+	return app.writeFormat(w, status, f, js, headers)
+}
+
+// wantsProblemJSON reports whether application/problem+json is one of the
+// media types in the Accept header, with a non-zero quality
+func (app *application) wantsProblemJSON(r *http.Request) bool {
+	for _, mr := range parseAccept(r) {
+		if mr.mediaType == "application/problem+json" && mr.q > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// translate looks key up in the catalog for the client's language, falling
+// back to the English message
+func (app *application) translate(r *http.Request, key string, params map[string]any, message string) string {
+	return app.localizer(r).Message([]string{key}, params, message)
+}
+
+// translateValidator localises each field error. The most specific message
+// wins: one for the field and code, e.g. "validation.genres.max_items", then
+// the code alone, e.g. "validation.max_items". Format errors look for one
+// for the format, e.g. "validation.format.integer", before the code alone.
+// Errors that carry suggestions look for a ".suggestions" variant of each key
+// first
+func translateValidator(l *i18n.Localizer, v *validator.Validator) *validator.Validator {
+	return v.Translate(func(key string, err validator.Error) string {
+		keys := []string{
+			"validation." + validator.Path(key) + "." + err.Code,
+			"validation." + err.Code,
+		}
+
+		if format, ok := err.Params["format"].(string); ok && err.Code == validator.CodeFormat {
+			keys = []string{keys[0], "validation.format." + format, keys[1]}
+		}
+
+		if _, ok := err.Params["suggestions"]; ok {
+			keys = append([]string{keys[0] + ".suggestions", keys[1] + ".suggestions"}, keys...)
+		}
+
+		return l.Message(keys, err.Params, err.Message)
+	})
+}
+
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	message := app.translate(r, "error.server_error", nil, "the server encountered a problem and could not process your request")
+	app.errorResponse(w, r, http.StatusInternalServerError, "server_error", message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the request resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	message := app.translate(r, "error.not_found", nil, "the request resource could not be found")
+	app.errorResponse(w, r, http.StatusNotFound, "not_found", message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
+	message := app.translate(r, "error.method_not_allowed", map[string]any{"method": r.Method},
+		fmt.Sprintf("the %s method is not supported for this resource", r.Method))
 
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, "method_not_allowed", message)
 }
 
+// Errors from readRequest are localised, anything else is passed on as it is.
+// A body the server can't decode at all is a 415 rather than a 400
 func (app *application) badRequestHandler(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	if errors.Is(err, errUnsupportedMediaType) {
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	if errors.Is(err, errUnsupportedContentEncoding) {
+		app.unsupportedContentEncodingResponse(w, r)
+		return
+	}
+
+	message := err.Error()
+
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		message = app.translate(r, "request."+requestErr.key, requestErr.params, requestErr.message)
+	}
+
+	app.errorResponse(w, r, http.StatusBadRequest, "bad_request", message)
+}
+
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, "failed_validation", v)
+}
+
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.not_acceptable", nil, "the resource can't be represented in any of the media types in the Accept header")
+	app.errorResponse(w, r, http.StatusNotAcceptable, "not_acceptable", message)
 }
 
-// Note the errors parameter here has the type map[string]string, exactly the same
-// as the errors map in the Validator
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.unsupported_media_type", map[string]any{"media_type": r.Header.Get("Content-Type")},
+		fmt.Sprintf("the %s content type is not supported", r.Header.Get("Content-Type")))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", message)
+}
+
+func (app *application) unsupportedContentEncodingResponse(w http.ResponseWriter, r *http.Request) {
+	encoding := r.Header.Get("Content-Encoding")
+	message := app.translate(r, "error.unsupported_content_encoding", map[string]any{"encoding": encoding},
+		fmt.Sprintf("the %s content encoding is not supported", encoding))
+
+	// Tell the client which encodings would have worked (RFC 9110, 15.5.16)
+	w.Header().Set("Accept-Encoding", strings.Join(contentEncodings, ", "))
+
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", message)
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	message := app.translate(r, "error.edit_conflict", nil, "unable to update the record due to an edit conflict, please try again")
+	app.errorResponse(w, r, http.StatusConflict, "edit_conflict", message)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
-	message := "rate limit exceeded"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	message := app.translate(r, "error.rate_limit_exceeded", nil, "rate limit exceeded")
+	app.errorResponse(w, r, http.StatusTooManyRequests, "rate_limit_exceeded", message)
+}
+
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.invalid_credentials", nil, "invalid authentication credentials")
+	app.errorResponse(w, r, http.StatusUnauthorized, "invalid_credentials", message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := app.translate(r, "error.invalid_authentication_token", nil, "invalid or missing authentication token")
+	app.errorResponse(w, r, http.StatusUnauthorized, "invalid_authentication_token", message)
+}
+
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.authentication_required", nil, "you must be authenticated to access this resource")
+	app.errorResponse(w, r, http.StatusUnauthorized, "authentication_required", message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.not_permitted", nil, "you do not have permission to access this resource")
+	app.errorResponse(w, r, http.StatusForbidden, "not_permitted", message)
 }
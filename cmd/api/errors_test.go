@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponseReplacesContentType(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		accept string
+		want   string
+	}{
+		{"nothing set", "", "", "application/json"},
+		{"stream started", "text/event-stream", "", "application/json"},
+		{"problem json", "text/event-stream", "application/problem+json", "application/problem+json"},
+		{"xml", "text/event-stream", "application/xml", "application/xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/events", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			if tt.preset != "" {
+				w.Header().Set("Content-Type", tt.preset)
+			}
+
+			app.notFoundResponse(w, r)
+
+			if got := w.Header().Get("Content-Type"); got != tt.want {
+				t.Errorf("got Content-Type %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		accept      string
+		language    string
+		status      int
+		contentType string
+		code        string
+		detail      string
+		fields      []string
+	}{
+		{
+			name:   "not found",
+			method: http.MethodGet, path: "/v1/movies/abc",
+			accept: "application/problem+json",
+			status: http.StatusNotFound, contentType: "application/problem+json",
+			code: "not_found", detail: "the request resource could not be found",
+		},
+		{
+			name:   "method not allowed",
+			method: http.MethodPut, path: "/v1/healthcheck",
+			accept: "application/json, application/problem+json",
+			status: http.StatusMethodNotAllowed, contentType: "application/problem+json",
+			code: "method_not_allowed", detail: "the PUT method is not supported for this resource",
+		},
+		{
+			name:   "failed validation",
+			method: http.MethodPost, path: "/v1/users", body: `{"name": "", "email": "alice", "password": "pa55"}`,
+			accept: "application/problem+json",
+			status: http.StatusUnprocessableEntity, contentType: "application/problem+json",
+			code: "failed_validation", detail: "one or more fields failed validation",
+			fields: []string{"email", "name", "password"},
+		},
+		{
+			name:   "localised",
+			method: http.MethodGet, path: "/v1/movies/abc",
+			accept: "application/problem+json", language: "fr",
+			status: http.StatusNotFound, contentType: "application/problem+json",
+			code: "not_found", detail: "la ressource demandée est introuvable",
+		},
+		{
+			name:   "refused",
+			method: http.MethodGet, path: "/v1/movies/abc",
+			accept: "application/problem+json;q=0, application/json",
+			status: http.StatusNotFound, contentType: "application/json",
+		},
+		{
+			name:   "not asked for",
+			method: http.MethodGet, path: "/v1/movies/abc",
+			status: http.StatusNotFound, contentType: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if tt.language != "" {
+				r.Header.Set("Accept-Language", tt.language)
+			}
+
+			w := httptest.NewRecorder()
+			app.routes().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d; want %d", w.Code, tt.status)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Fatalf("got Content-Type %q; want %q", got, tt.contentType)
+			}
+
+			var body map[string]any
+
+			err := json.Unmarshal(w.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.code == "" {
+				if _, ok := body["error"]; !ok {
+					t.Errorf("got %v; want the plain error envelope", body)
+				}
+				return
+			}
+
+			want := map[string]any{
+				"type":     "urn:greenlight:problem:" + tt.code,
+				"title":    http.StatusText(tt.status),
+				"status":   float64(tt.status),
+				"instance": tt.path,
+				"code":     tt.code,
+				"detail":   tt.detail,
+			}
+
+			for key, value := range want {
+				if body[key] != value {
+					t.Errorf("got %s %v; want %v", key, body[key], value)
+				}
+			}
+
+			errs, _ := body["errors"].(map[string]any)
+			if len(errs) != len(tt.fields) {
+				t.Errorf("got errors %v; want ones for %v", body["errors"], tt.fields)
+			}
+
+			for _, field := range tt.fields {
+				if _, ok := errs[field]; !ok {
+					t.Errorf("got no errors for %s", field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/lib/pq"
+)
+
+const (
+	movieCreated = "created"
+	movieUpdated = "updated"
+	movieDeleted = "deleted"
+)
+
+// movieEvent is a change to a movie. Seq is the id of the row in
+// movie_events, Movie is the movie as it was read after the change and is
+// nil for deletes
+type movieEvent struct {
+	Seq   int64
+	Type  string
+	ID    int64
+	Movie *data.Movie
+}
+
+// movieBufferSize is how many events a subscriber can fall behind by before
+// it's dropped
+const movieBufferSize = 64
+
+// movieBroker fans movie changes out to everyone watching. It's fed by
+// feedMovieEvents, so it sees changes made by every instance of the API
+// and straight to the database
+type movieBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan movieEvent]struct{}
+	done        chan struct{}
+	closed      bool
+}
+
+func newMovieBroker() *movieBroker {
+	return &movieBroker{
+		subscribers: make(map[chan movieEvent]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// subscribe returns a channel of events and a function to stop them. The
+// channel is closed if the subscriber falls more than movieBufferSize
+// events behind, a slow client mustn't hold up everyone else
+func (b *movieBroker) subscribe() (<-chan movieEvent, func()) {
+	ch := make(chan movieEvent, movieBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *movieBroker) publish(event movieEvent) {
+	if event.Movie != nil {
+		movie := *event.Movie
+		event.Movie = &movie
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// stop marks the end of the feed. Subscribers' channels are left open, so
+// they can tell shutdown apart from being dropped by waiting on closing()
+func (b *movieBroker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
+
+// closing is closed once no more events will be published
+func (b *movieBroker) closing() <-chan struct{} {
+	return b.done
+}
+
+// movieEventBatch is how many stored events are read at a time when
+// catching up
+const movieEventBatch = 100
+
+// feedMovieEvents listens for the notifications the movies trigger sends
+// and publishes them until ctx is cancelled, then stops the broker. Until
+// it's listening there are simply no events, SSE clients stay connected
+func (app *application) feedMovieEvents(ctx context.Context) {
+	defer app.events.stop()
+
+	listener := pq.NewListener(app.config.db.dsn, time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"listener": data.MovieEventsChannel})
+		}
+	})
+	defer listener.Close()
+
+	// Listen waits for the connection while the database is down, closing
+	// the listener is the only way to stop it waiting when we shut down
+	stopClosing := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stopClosing()
+
+	// The listener reconnects by itself, but LISTEN failing outright is left
+	// to us
+	for delay := time.Second; ; delay = min(delay*2, time.Minute) {
+		err := listener.Listen(data.MovieEventsChannel)
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			break
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		app.logger.PrintError(err, map[string]string{"listener": data.MovieEventsChannel, "retry_in": delay.String()})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	// Subscribers only get changes from now on, the SSE handler replays
+	// older ones itself
+	_, last, err := app.models.MovieEvents.Bounds()
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"listener": data.MovieEventsChannel})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case n, ok := <-listener.Notify:
+			// Closed as we shut down
+			if !ok {
+				return
+			}
+
+			// A nil notification means the connection was re-established,
+			// anything sent while it was down has to be read from the table
+			if n == nil {
+				last = app.catchUpMovieEvents(last)
+				continue
+			}
+
+			var event data.MovieEvent
+
+			err := json.Unmarshal([]byte(n.Extra), &event)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"listener": data.MovieEventsChannel})
+				continue
+			}
+
+			app.publishMovieEvent(&event)
+			last = max(last, event.ID)
+
+		case <-time.After(90 * time.Second):
+			// Nothing for a while, make sure the connection is still alive
+			go listener.Ping()
+		}
+	}
+}
+
+// catchUpMovieEvents publishes the stored events after last and returns the
+// id of the newest one
+func (app *application) catchUpMovieEvents(last int64) int64 {
+	for {
+		events, err := app.models.MovieEvents.GetAfter(last, movieEventBatch)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"listener": data.MovieEventsChannel})
+			return last
+		}
+
+		for _, event := range events {
+			app.publishMovieEvent(event)
+			last = event.ID
+		}
+
+		if len(events) < movieEventBatch {
+			return last
+		}
+	}
+}
+
+func (app *application) publishMovieEvent(event *data.MovieEvent) {
+	movie, err := app.loadMovieEvent(event)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"movie_event": strconv.FormatInt(event.ID, 10)})
+		return
+	}
+
+	app.events.publish(movieEvent{Seq: event.ID, Type: event.Type, ID: event.MovieID, Movie: movie})
+}
+
+// loadMovieEvent reads the movie an event is about. Events are only
+// recorded after the change so the movie may have moved on since, or gone,
+// in which case it's nil
+func (app *application) loadMovieEvent(event *data.MovieEvent) (*data.Movie, error) {
+	if event.Type == movieDeleted {
+		return nil, nil
+	}
+
+	movie, err := app.models.Movies.Get(event.MovieID)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return movie, err
+}
+
+// pruneMovieEvents drops the events older than the retention period
+func (app *application) pruneMovieEvents(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	rows, err := app.models.MovieEvents.DeleteOlderThan(ctx, time.Now().Add(-app.config.events.retention))
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("movie event pruning complete", map[string]string{
+		"rows": strconv.FormatInt(rows, 10),
+	})
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFeedMovieEventsWithoutDatabase(t *testing.T) {
+	app := newTestApplication(t)
+	// Nothing listens on port 1, so every connection is refused
+	app.config.db.dsn = "postgres://greenlight@127.0.0.1:1/greenlight?sslmode=disable&connect_timeout=1"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		app.feedMovieEvents(ctx)
+		close(done)
+	}()
+
+	// SSE clients stay connected while the database is unreachable
+	select {
+	case <-app.events.closing():
+		t.Fatal("broker stopped while the database was unreachable")
+	case <-done:
+		t.Fatal("feedMovieEvents returned while the database was unreachable")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("feedMovieEvents didn't return after being cancelled")
+	}
+
+	select {
+	case <-app.events.closing():
+	default:
+		t.Error("broker wasn't stopped after being cancelled")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) showMovieByExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	provider := params.ByName("provider")
+	externalID := params.ByName("external_id")
+
+	v := validator.New()
+
+	// An id that could never exist is a 404 just like a bad movie id
+	if data.ValidateExternalID(v, provider, externalID); !v.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.models.ExternalIDs.GetMovieID(provider, externalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movieResource{Movie: movie, Links: movieLinks(movie.ID)}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	provider := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	var input struct {
+		ExternalID string `json:"external_id"`
+	}
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestHandler(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateExternalID(v, provider, input.ExternalID); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.ExternalIDs.Set(movie.ID, provider, input.ExternalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_id", "is already linked to another movie")
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if movie.ExternalIDs == nil {
+		movie.ExternalIDs = data.ExternalIDs{}
+	}
+
+	movie.ExternalIDs[provider] = input.ExternalID
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movieResource{Movie: movie, Links: movieLinks(movie.ID)}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	provider := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	err = app.models.ExternalIDs.Delete(id, provider)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "external id successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
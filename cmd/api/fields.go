@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+// expansions is a tree of related resources, e.g. expand=credits.person is
+// {"credits": {"person": {}}}
+type expansions map[string]expansions
+
+// movieExpansions are what ?expand= can inline into a movie
+var movieExpansions = expansions{
+	"credits": {"person": {}},
+	"ratings": {},
+}
+
+// maxExpandDepth keeps one request from walking the whole graph, the
+// deepest expansion today is credits.person
+const maxExpandDepth = 2
+
+// readFields reads a sparse fieldset such as fields=id,title,year. Every
+// field must be in allowed
+func (app *application) readFields(qs url.Values, key string, allowed []string, v *validator.Validator) []string {
+	var fields []string
+
+	for _, field := range app.readCSV(qs, key, nil) {
+		field = strings.TrimSpace(field)
+
+		if !validator.PermittedValue(field, allowed...) {
+			v.Add(key, validator.NewError(validator.CodeUnknown, fmt.Sprintf("%q is not known", field), validator.Params{"value": field}))
+			continue
+		}
+
+		if !validator.PermittedValue(field, fields...) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// readExpand reads related resources to inline, such as
+// expand=credits.person,ratings. Asking for credits.person expands credits
+// as well
+func (app *application) readExpand(qs url.Values, key string, allowed expansions, v *validator.Validator) expansions {
+	expand := expansions{}
+
+	for _, path := range app.readCSV(qs, key, nil) {
+		path = strings.TrimSpace(path)
+		names := strings.Split(path, ".")
+
+		if len(names) > maxExpandDepth {
+			v.Add(key, validator.NewError(validator.CodeMax,
+				fmt.Sprintf("must not be nested more than %d levels deep", maxExpandDepth), validator.Params{"max": maxExpandDepth}))
+			continue
+		}
+
+		requested, permitted := expand, allowed
+
+		for _, name := range names {
+			next, ok := permitted[name]
+			if !ok {
+				v.Add(key, validator.NewError(validator.CodeUnknown, fmt.Sprintf("%q is not known", path), validator.Params{"value": path}))
+				break
+			}
+
+			if requested[name] == nil {
+				requested[name] = expansions{}
+			}
+
+			requested, permitted = requested[name], next
+		}
+	}
+
+	return expand
+}
+
+// expandMovies fetches the related resources in expand for every movie at
+// once, keyed by movie id and then by the name they are inlined under
+func (app *application) expandMovies(movies []*data.Movie, expand expansions) (map[int64]envelope, error) {
+	ids := make([]int64, len(movies))
+	extra := make(map[int64]envelope, len(movies))
+
+	for i, movie := range movies {
+		ids[i] = movie.ID
+		extra[movie.ID] = envelope{}
+	}
+
+	if len(ids) == 0 {
+		return extra, nil
+	}
+
+	if credits, ok := expand["credits"]; ok {
+		byMovie, err := app.models.Credits.GetAllForMovies(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := credits["person"]; ok {
+			var personIDs []int64
+			for _, movieCredits := range byMovie {
+				for _, credit := range movieCredits {
+					personIDs = append(personIDs, credit.PersonID)
+				}
+			}
+
+			people, err := app.models.People.GetAllByID(personIDs)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, movieCredits := range byMovie {
+				for _, credit := range movieCredits {
+					credit.Person = people[credit.PersonID]
+				}
+			}
+		}
+
+		for _, id := range ids {
+			movieCredits := byMovie[id]
+			if movieCredits == nil {
+				movieCredits = []*data.Credit{}
+			}
+			extra[id]["credits"] = movieCredits
+		}
+	}
+
+	if _, ok := expand["ratings"]; ok {
+		summaries, err := app.models.Ratings.GetSummaries(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			extra[id]["ratings"] = summaries[id]
+		}
+	}
+
+	return extra, nil
+}
+
+// sparseMovies cuts each movie down to fields and adds whatever
+// expandMovies fetched for it, along with the movie's links. Without fields
+// or expand every field is sent
+func (app *application) sparseMovies(movies []*data.Movie, fields []string, expand expansions) ([]any, error) {
+	sparse := make([]any, len(movies))
+
+	if len(fields) == 0 && len(expand) == 0 {
+		for i, movie := range movies {
+			sparse[i] = movieResource{Movie: movie, Links: movieLinks(movie.ID)}
+		}
+		return sparse, nil
+	}
+
+	extra, err := app.expandMovies(movies, expand)
+	if err != nil {
+		return nil, err
+	}
+
+	// Localize sets these alongside the title
+	if validator.PermittedValue("title", fields...) {
+		fields = append(fields, "original_title", "language")
+	}
+
+	for i, movie := range movies {
+		projected := envelope{}
+
+		// The fields are kept as they are rather than encoded here, so
+		// runtimes can still be written in the format asked for
+		for _, field := range jsonFields(reflect.ValueOf(movie).Elem()) {
+			if len(fields) == 0 || field.name == "id" || validator.PermittedValue(field.name, fields...) {
+				projected[field.name] = field.value.Interface()
+			}
+		}
+
+		for key, value := range extra[movie.ID] {
+			projected[key] = value
+		}
+
+		projected["links"] = movieLinks(movie.ID)
+
+		sparse[i] = projected
+	}
+
+	return sparse, nil
+}
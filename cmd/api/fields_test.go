@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+func TestReadFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      []string
+		wantCodes []string
+	}{
+		{"none", "", nil, nil},
+		{"some", "fields=id,title,year", []string{"id", "title", "year"}, nil},
+		{"spaces", "fields=title, year", []string{"title", "year"}, nil},
+		{"repeated", "fields=title,title", []string{"title"}, nil},
+		{"unknown", "fields=title,password", []string{"title"}, []string{validator.CodeUnknown}},
+		{"two unknown", "fields=budget,password", nil, []string{validator.CodeUnknown, validator.CodeUnknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			qs, _ := url.ParseQuery(tt.query)
+			v := validator.New()
+
+			got := app.readFields(qs, "fields", data.MovieFields, v)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+
+			var codes []string
+			for _, err := range v.Fields["fields"] {
+				codes = append(codes, err.Code)
+			}
+
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("got errors %v; want %v", v.Fields["fields"], tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestReadExpand(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      expansions
+		wantCodes []string
+	}{
+		{"none", "", expansions{}, nil},
+		{"one", "expand=ratings", expansions{"ratings": {}}, nil},
+		{"nested", "expand=credits.person", expansions{"credits": {"person": {}}}, nil},
+		{"merged", "expand=credits,credits.person,ratings", expansions{"credits": {"person": {}}, "ratings": {}}, nil},
+		{"unknown", "expand=reviews", expansions{}, []string{validator.CodeUnknown}},
+		{"unknown nested", "expand=credits.movie", expansions{"credits": {}}, []string{validator.CodeUnknown}},
+		{"too deep", "expand=credits.person.credits", expansions{}, []string{validator.CodeMax}},
+		{"too deep and known", "expand=credits.person.credits,ratings", expansions{"ratings": {}}, []string{validator.CodeMax}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			qs, _ := url.ParseQuery(tt.query)
+			v := validator.New()
+
+			got := app.readExpand(qs, "expand", movieExpansions, v)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+
+			var codes []string
+			for _, err := range v.Fields["expand"] {
+				codes = append(codes, err.Code)
+			}
+
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("got errors %v; want %v", v.Fields["expand"], tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestSparseMovies(t *testing.T) {
+	// As Localize leaves a movie with a French title
+	movie := &data.Movie{ID: 1, Title: "Le Huitième Passager", OriginalTitle: "Alien", Language: "fr", Year: 1979, Runtime: 117}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+	}{
+		{"id always sent", []string{"year"}, []string{"id", "links", "year"}},
+		{"title brings its translation", []string{"title"}, []string{"id", "language", "links", "original_title", "title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			sparse, err := app.sparseMovies([]*data.Movie{movie}, tt.fields, expansions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var keys []string
+			for key := range sparse[0].(envelope) {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			if !slices.Equal(keys, tt.want) {
+				t.Errorf("got %v; want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowMovieFieldsAreValidated(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		language string
+		field    string
+		code     string
+		message  string
+	}{
+		{"unknown field", "fields=title,budget", "", "fields", "unknown", `"budget" is not known`},
+		{"unknown expansion", "expand=reviews", "", "expand", "unknown", `"reviews" is not known`},
+		{"too deep", "expand=credits.person.credits", "", "expand", "max", "must not be nested more than 2 levels deep"},
+		{"localised", "expand=credits.person.credits", "fr", "expand", "max", "ne doit pas être imbriqué sur plus de 2 niveaux"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			// Validation happens before the database is touched, so the
+			// test models are never used
+			req := httptest.NewRequest(http.MethodGet, "/v1/movies/1?"+tt.query, nil)
+			if tt.language != "" {
+				req.Header.Set("Accept-Language", tt.language)
+			}
+
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d", rr.Code, http.StatusUnprocessableEntity)
+			}
+
+			var body struct {
+				Fields map[string][]struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"fields"`
+			}
+
+			err := json.NewDecoder(rr.Body).Decode(&body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := body.Fields[tt.field]
+			if len(got) != 1 || got[0].Code != tt.code || got[0].Message != tt.message {
+				t.Errorf("got %s errors %+v; want %s %q", tt.field, got, tt.code, tt.message)
+			}
+		})
+	}
+}
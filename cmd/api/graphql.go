@@ -0,0 +1,890 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+// graphqlRequest is the body of POST /v1/graphql. Extensions is accepted
+// so clients that send it aren't turned away, but nothing reads it
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"`
+}
+
+const graphqlContextKey = contextKey("graphql")
+
+// graphqlContext is what resolvers need from the request. The loaders live
+// for a single request so nothing is cached between clients
+type graphqlContext struct {
+	r       *http.Request
+	credits *loader[[]*data.Credit]
+	people  *loader[*data.Person]
+	ratings *loader[*data.RatingSummary]
+}
+
+func (app *application) newGraphQLContext(r *http.Request) *graphqlContext {
+	onError := func(err error) error {
+		return app.graphqlServerError(r, err)
+	}
+
+	return &graphqlContext{
+		r:       r,
+		credits: newLoader(app.models.Credits.GetAllForMovies, onError),
+		people:  newLoader(app.models.People.GetAllByID, onError),
+		ratings: newLoader(app.models.Ratings.GetSummaries, onError),
+	}
+}
+
+func gqlContext(p graphql.ResolveParams) *graphqlContext {
+	return p.Context.Value(graphqlContextKey).(*graphqlContext)
+}
+
+// graphqlHandler serves queries and mutations against schema. Errors are
+// reported the GraphQL way, in the errors list of a 200 response, apart from
+// a body that isn't a GraphQL request at all
+func (app *application) graphqlHandler(schema graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input graphqlRequest
+
+		err := app.readRequest(w, r, &input)
+		if err != nil {
+			app.badRequestHandler(w, r, err)
+			return
+		}
+
+		v := validator.New()
+
+		if v.CheckError(input.Query != "", "query", validator.Required()); !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+
+		var env envelope
+
+		// A query over the limits never runs, so like a query that doesn't
+		// parse the response has no data
+		if err := app.checkGraphQLLimits(r, input); err != nil {
+			env = envelope{"errors": []gqlerrors.FormattedError{{
+				Message:    err.Error(),
+				Locations:  []location.SourceLocation{},
+				Extensions: err.Extensions(),
+			}}}
+		} else {
+			result := graphql.Do(graphql.Params{
+				Schema:         schema,
+				RequestString:  input.Query,
+				VariableValues: input.Variables,
+				OperationName:  input.OperationName,
+				Context:        context.WithValue(r.Context(), graphqlContextKey, app.newGraphQLContext(r)),
+			})
+
+			env = envelope{"data": result.Data}
+			if len(result.Errors) > 0 {
+				env["errors"] = withGraphQLExtensions(result.Errors)
+			}
+		}
+
+		err = app.writeResponse(w, r, http.StatusOK, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// withGraphQLExtensions fills in the extensions of errors returned by
+// thunks, such as the loaders'. graphql-go wraps those twice and loses the
+// extensions on the way
+func withGraphQLExtensions(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i := range errs {
+		if errs[i].Extensions == nil {
+			errs[i].Extensions = originalExtensions(errs[i].OriginalError())
+		}
+	}
+
+	return errs
+}
+
+// originalExtensions unwraps err down to the error a resolver returned
+func originalExtensions(err error) map[string]any {
+	for {
+		switch e := err.(type) {
+		case gqlerrors.ExtendedError:
+			return e.Extensions()
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return nil
+		}
+	}
+}
+
+// graphqlError is a resolver error carrying the same code, and for
+// validation the same fields, as the REST error response would
+type graphqlError struct {
+	code    string
+	message string
+	fields  map[string][]validator.Error
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]any {
+	extensions := map[string]any{"code": e.code}
+	if e.fields != nil {
+		extensions["fields"] = e.fields
+	}
+
+	return extensions
+}
+
+func (app *application) graphqlServerError(r *http.Request, err error) error {
+	app.logError(r, err)
+
+	message := app.translate(r, "error.server_error", nil, "the server encountered a problem and could not process your request")
+	return &graphqlError{code: "server_error", message: message}
+}
+
+func (app *application) graphqlNotFound(r *http.Request) error {
+	message := app.translate(r, "error.not_found", nil, "the request resource could not be found")
+	return &graphqlError{code: "not_found", message: message}
+}
+
+func (app *application) graphqlEditConflict(r *http.Request) error {
+	message := app.translate(r, "error.edit_conflict", nil, "unable to update the record due to an edit conflict, please try again")
+	return &graphqlError{code: "edit_conflict", message: message}
+}
+
+func (app *application) graphqlAuthenticationRequired(r *http.Request) error {
+	message := app.translate(r, "error.authentication_required", nil, "you must be authenticated to access this resource")
+	return &graphqlError{code: "authentication_required", message: message}
+}
+
+func (app *application) graphqlNotPermitted(r *http.Request) error {
+	message := app.translate(r, "error.not_permitted", nil, "you do not have permission to access this resource")
+	return &graphqlError{code: "not_permitted", message: message}
+}
+
+// graphqlRequirePermission is requirePermission for a single field, the
+// rest of the query still runs
+func (app *application) graphqlRequirePermission(code string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		r := gqlContext(p).r
+
+		user := app.contextGetUser(r)
+		if user.IsAnonymous() {
+			return nil, app.graphqlAuthenticationRequired(r)
+		}
+
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			return nil, app.graphqlServerError(r, err)
+		}
+
+		if !permissions.Include(code) {
+			return nil, app.graphqlNotPermitted(r)
+		}
+
+		return resolve(p)
+	}
+}
+
+func (app *application) graphqlFailedValidation(r *http.Request, v *validator.Validator) error {
+	l := app.localizer(r)
+	v = translateValidator(l, v)
+
+	message := l.Message([]string{"error.failed_validation"}, nil, "one or more fields failed validation")
+	return &graphqlError{code: "failed_validation", message: message, fields: v.Fields}
+}
+
+// listMultipliers estimate how many objects a list field returns, for the
+// complexity of the fields selected inside it. The movies query returns
+// pageSize movies, the number here is only used when it isn't given. The
+// page's own movies field is that same list so it isn't counted again
+var listMultipliers = map[string]int{
+	"movies":  20,
+	"credits": 10,
+}
+
+// checkGraphQLLimits rejects queries nested deeper than cfg.graphql.maxDepth
+// or with a complexity, roughly the number of objects in the response, over
+// cfg.graphql.maxComplexity. Queries that don't parse are left for graphql-go
+// to report
+func (app *application) checkGraphQLLimits(r *http.Request, input graphqlRequest) *graphqlError {
+	doc, err := parser.Parse(parser.ParseParams{Source: input.Query})
+	if err != nil {
+		return nil
+	}
+
+	qw := &queryWalker{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		visiting:  make(map[string]bool),
+		variables: input.Variables,
+	}
+
+	var operation *ast.OperationDefinition
+
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			qw.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if input.OperationName == "" || (definition.Name != nil && definition.Name.Value == input.OperationName) {
+				operation = definition
+			}
+		}
+	}
+
+	if operation == nil {
+		return nil
+	}
+
+	depth, complexity := qw.walk(operation.SelectionSet, 1, "")
+
+	if limit := app.config.graphql.maxDepth; depth > limit {
+		message := app.translate(r, "error.query_too_deep", map[string]any{"max": limit},
+			fmt.Sprintf("the query must not be nested more than %d levels deep", limit))
+		return &graphqlError{code: "query_too_deep", message: message}
+	}
+
+	if limit := app.config.graphql.maxComplexity; complexity > limit {
+		message := app.translate(r, "error.query_too_complex", map[string]any{"complexity": complexity, "max": limit},
+			fmt.Sprintf("the query could return up to %d objects, the limit is %d", complexity, limit))
+		return &graphqlError{code: "query_too_complex", message: message}
+	}
+
+	return nil
+}
+
+// queryWalker measures a query, following fragment spreads into their
+// definitions
+type queryWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	visiting  map[string]bool
+	variables map[string]any
+}
+
+// walk returns how deep set goes and its complexity, where every field
+// counts one and the fields inside a list count once per item. Introspection
+// fields don't count. parent is the field set was selected on
+func (qw *queryWalker) walk(set *ast.SelectionSet, multiplier int, parent string) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			if strings.HasPrefix(name, "__") {
+				continue
+			}
+
+			inner := multiplier
+			if n, ok := listMultipliers[name]; ok && name != parent {
+				inner *= qw.intArgument(selection, "pageSize", n)
+			}
+
+			d, c = qw.walk(selection.SelectionSet, inner, name)
+			d, c = d+1, c+multiplier
+
+		case *ast.InlineFragment:
+			d, c = qw.walk(selection.SelectionSet, multiplier, parent)
+
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+
+			// Fragment cycles are a validation error, graphql-go reports them
+			fragment, ok := qw.fragments[name]
+			if !ok || qw.visiting[name] {
+				continue
+			}
+
+			qw.visiting[name] = true
+			d, c = qw.walk(fragment.SelectionSet, multiplier, parent)
+			qw.visiting[name] = false
+		}
+
+		depth = max(depth, d)
+		complexity += c
+	}
+
+	return depth, complexity
+}
+
+// intArgument reads an Int argument given either inline or as a variable
+func (qw *queryWalker) intArgument(f *ast.Field, name string, fallback int) int {
+	for _, argument := range f.Arguments {
+		if argument.Name.Value != name {
+			continue
+		}
+
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			if n, err := strconv.Atoi(fmt.Sprint(qw.variables[value.Name.Value])); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+
+	return fallback
+}
+
+// moviePage is the result of the movies query
+type moviePage struct {
+	movies   []*data.Movie
+	metadata data.Metadata
+}
+
+// field is a GraphQL field read straight off its parent, which has to be a T
+func field[T any](typ graphql.Output, get func(T) any) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return get(p.Source.(T)), nil
+		},
+	}
+}
+
+// nullable turns the zero value of optional fields into null
+func nullable[T comparable](value T) any {
+	var zero T
+	if value == zero {
+		return nil
+	}
+
+	return value
+}
+
+func nonNull(typ graphql.Type) graphql.Type {
+	return graphql.NewNonNull(typ)
+}
+
+func listOf(typ graphql.Type) graphql.Type {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(typ)))
+}
+
+// graphqlSchema builds the schema for /v1/graphql. Field names are the
+// camelCase versions of the JSON ones
+func (app *application) graphqlSchema() (graphql.Schema, error) {
+	personType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Person",
+		Fields: graphql.Fields{
+			"id":        field(nonNull(graphql.ID), func(p *data.Person) any { return p.ID }),
+			"name":      field(nonNull(graphql.String), func(p *data.Person) any { return p.Name }),
+			"biography": field(graphql.String, func(p *data.Person) any { return nullable(p.Biography) }),
+			"birthYear": field(graphql.Int, func(p *data.Person) any { return nullable(p.BirthYear) }),
+		},
+	})
+
+	creditType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Credit",
+		Fields: graphql.Fields{
+			"id":           field(nonNull(graphql.ID), func(c *data.Credit) any { return c.ID }),
+			"role":         field(nonNull(graphql.String), func(c *data.Credit) any { return c.Role }),
+			"job":          field(graphql.String, func(c *data.Credit) any { return nullable(c.Job) }),
+			"character":    field(graphql.String, func(c *data.Credit) any { return nullable(c.Character) }),
+			"billingOrder": field(nonNull(graphql.Int), func(c *data.Credit) any { return c.BillingOrder }),
+			"person": &graphql.Field{
+				Type: personType,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					credit := p.Source.(*data.Credit)
+					return gqlContext(p).people.load(credit.PersonID), nil
+				},
+			},
+		},
+	})
+
+	ratingSummaryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "RatingSummary",
+		Description: "distribution[i] is the number of ratings of i+1",
+		Fields: graphql.Fields{
+			"average":      field(nonNull(graphql.Float), func(s *data.RatingSummary) any { return s.Average }),
+			"count":        field(nonNull(graphql.Int), func(s *data.RatingSummary) any { return s.Count }),
+			"distribution": field(listOf(graphql.Int), func(s *data.RatingSummary) any { return s.Distribution[:] }),
+		},
+	})
+
+	releaseDateType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReleaseDate",
+		Fields: graphql.Fields{
+			"country":       field(nonNull(graphql.String), func(d data.ReleaseDate) any { return d.Country }),
+			"date":          field(nonNull(graphql.String), func(d data.ReleaseDate) any { return d.Date }),
+			"certification": field(graphql.String, func(d data.ReleaseDate) any { return nullable(d.Certification) }),
+		},
+	})
+
+	movieType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Movie",
+		Fields: graphql.Fields{
+			"id":               field(nonNull(graphql.ID), func(m *data.Movie) any { return m.ID }),
+			"title":            field(nonNull(graphql.String), func(m *data.Movie) any { return m.Title }),
+			"originalTitle":    field(graphql.String, func(m *data.Movie) any { return nullable(m.OriginalTitle) }),
+			"language":         field(graphql.String, func(m *data.Movie) any { return nullable(m.Language) }),
+			"year":             field(graphql.Int, func(m *data.Movie) any { return nullable(m.Year) }),
+			"runtime":          field(graphql.Int, func(m *data.Movie) any { return nullable(int32(m.Runtime)) }),
+			"genres":           field(listOf(graphql.String), func(m *data.Movie) any { return m.Genres }),
+			"version":          field(nonNull(graphql.Int), func(m *data.Movie) any { return m.Version }),
+			"averageRating":    field(nonNull(graphql.Float), func(m *data.Movie) any { return m.AverageRating }),
+			"ratingCount":      field(nonNull(graphql.Int), func(m *data.Movie) any { return m.RatingCount }),
+			"overview":         field(graphql.String, func(m *data.Movie) any { return nullable(m.Overview) }),
+			"tagline":          field(graphql.String, func(m *data.Movie) any { return nullable(m.Tagline) }),
+			"originalLanguage": field(graphql.String, func(m *data.Movie) any { return nullable(m.OriginalLanguage) }),
+			"status":           field(nonNull(graphql.String), func(m *data.Movie) any { return m.Status }),
+			"releaseDates":     field(listOf(releaseDateType), func(m *data.Movie) any { return m.ReleaseDates }),
+			"credits": &graphql.Field{
+				Type: listOf(creditType),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					movie := p.Source.(*data.Movie)
+					credits := gqlContext(p).credits.load(movie.ID)
+
+					return func() (any, error) {
+						value, err := credits()
+						if value.([]*data.Credit) == nil {
+							return []*data.Credit{}, err
+						}
+						return value, err
+					}, nil
+				},
+			},
+			"ratings": &graphql.Field{
+				Type: nonNull(ratingSummaryType),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					movie := p.Source.(*data.Movie)
+					return gqlContext(p).ratings.load(movie.ID), nil
+				},
+			},
+		},
+	})
+
+	metadataType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Metadata",
+		Fields: graphql.Fields{
+			"currentPage":  field(graphql.Int, func(m data.Metadata) any { return nullable(m.CurrentPage) }),
+			"pageSize":     field(graphql.Int, func(m data.Metadata) any { return nullable(m.PageSize) }),
+			"firstPage":    field(graphql.Int, func(m data.Metadata) any { return nullable(m.FirstPage) }),
+			"lastPage":     field(graphql.Int, func(m data.Metadata) any { return nullable(m.LastPage) }),
+			"totalRecords": field(nonNull(graphql.Int), func(m data.Metadata) any { return m.TotalRecords }),
+		},
+	})
+
+	moviePageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MoviePage",
+		Fields: graphql.Fields{
+			"movies":   field(listOf(movieType), func(p *moviePage) any { return p.movies }),
+			"metadata": field(nonNull(metadataType), func(p *moviePage) any { return p.metadata }),
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        field(nonNull(graphql.ID), func(u *data.User) any { return u.ID }),
+			"name":      field(nonNull(graphql.String), func(u *data.User) any { return u.Name }),
+			"email":     field(nonNull(graphql.String), func(u *data.User) any { return u.Email }),
+			"activated": field(nonNull(graphql.Boolean), func(u *data.User) any { return u.Activated }),
+			"createdAt": field(nonNull(graphql.DateTime), func(u *data.User) any { return u.CreatedAt }),
+			"permissions": &graphql.Field{
+				Type: listOf(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := p.Source.(*data.User)
+
+					permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+					if err != nil {
+						return nil, app.graphqlServerError(gqlContext(p).r, err)
+					}
+
+					return []string(permissions), nil
+				},
+			},
+		},
+	})
+
+	releaseDateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ReleaseDateInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"country":       &graphql.InputObjectFieldConfig{Type: nonNull(graphql.String)},
+			"date":          &graphql.InputObjectFieldConfig{Type: nonNull(graphql.String)},
+			"certification": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	// Every field is optional so the same input works for updates, creating
+	// a movie still needs the ones ValidateMovie requires
+	movieInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "MovieInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":            &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"year":             &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"runtime":          &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"genres":           &graphql.InputObjectFieldConfig{Type: graphql.NewList(nonNull(graphql.String))},
+			"overview":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"tagline":          &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"originalLanguage": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"status":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"releaseDates":     &graphql.InputObjectFieldConfig{Type: graphql.NewList(nonNull(releaseDateInput))},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movies": &graphql.Field{
+				Type: nonNull(moviePageType),
+				Args: graphql.FieldConfigArgument{
+					"title":            &graphql.ArgumentConfig{Type: graphql.String},
+					"genres":           &graphql.ArgumentConfig{Type: graphql.NewList(nonNull(graphql.String))},
+					"personId":         &graphql.ArgumentConfig{Type: graphql.ID},
+					"collectionId":     &graphql.ArgumentConfig{Type: graphql.ID},
+					"status":           &graphql.ArgumentConfig{Type: graphql.String},
+					"originalLanguage": &graphql.ArgumentConfig{Type: graphql.String},
+					"country":          &graphql.ArgumentConfig{Type: graphql.String},
+					"certification":    &graphql.ArgumentConfig{Type: graphql.String},
+					"releasedFrom":     &graphql.ArgumentConfig{Type: graphql.String},
+					"releasedTo":       &graphql.ArgumentConfig{Type: graphql.String},
+					"page":             &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"pageSize":         &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+					"sort":             &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "id"},
+				},
+				Resolve: app.resolveMovies,
+			},
+			"movie": &graphql.Field{
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: nonNull(graphql.ID)},
+				},
+				Resolve: app.resolveMovie,
+			},
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "The authenticated user, null for anonymous requests",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := app.contextGetUser(gqlContext(p).r)
+					if user.IsAnonymous() {
+						return nil, nil
+					}
+
+					return user, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": &graphql.Field{
+				Type: nonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: nonNull(movieInput)},
+				},
+				Resolve: app.graphqlRequirePermission("movies:write", app.resolveCreateMovie),
+			},
+			"updateMovie": &graphql.Field{
+				Type: nonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: nonNull(graphql.ID)},
+					// When given the update fails with edit_conflict unless the
+					// movie is still at this version
+					"version": &graphql.ArgumentConfig{Type: graphql.Int},
+					"input":   &graphql.ArgumentConfig{Type: nonNull(movieInput)},
+				},
+				Resolve: app.graphqlRequirePermission("movies:write", app.resolveUpdateMovie),
+			},
+			"deleteMovie": &graphql.Field{
+				Type: nonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: nonNull(graphql.ID)},
+				},
+				Resolve: app.graphqlRequirePermission("movies:write", app.resolveDeleteMovie),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// graphqlArgKeys maps the movies query's arguments to the query string
+// parameters of GET /v1/movies
+var graphqlArgKeys = map[string]string{
+	"personId":         "person_id",
+	"collectionId":     "collection_id",
+	"originalLanguage": "original_language",
+	"releasedFrom":     "released_from",
+	"releasedTo":       "released_to",
+	"pageSize":         "page_size",
+}
+
+// resolveMovies turns its arguments into the query string GET /v1/movies
+// would get, so the filters are read and validated in one place
+func (app *application) resolveMovies(p graphql.ResolveParams) (any, error) {
+	r := gqlContext(p).r
+
+	qs := url.Values{}
+
+	for name, value := range p.Args {
+		key := name
+		if k, ok := graphqlArgKeys[name]; ok {
+			key = k
+		}
+
+		switch value := value.(type) {
+		case []any:
+			values := make([]string, len(value))
+			for i, v := range value {
+				values[i] = fmt.Sprint(v)
+			}
+			qs.Set(key, strings.Join(values, ","))
+		default:
+			qs.Set(key, fmt.Sprint(value))
+		}
+	}
+
+	v := validator.New()
+
+	criteria, filters := app.readMovieListQuery(qs, v)
+	if !v.Valid() {
+		return nil, app.graphqlFailedValidation(r, v)
+	}
+
+	genres, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		return nil, app.graphqlServerError(r, err)
+	}
+
+	criteria.Genres = genres.Canonicalize(criteria.Genres)
+
+	movies, metadata, err := app.models.Movies.GetAll(criteria, filters)
+	if err != nil {
+		return nil, app.graphqlServerError(r, err)
+	}
+
+	if preferred := app.readAcceptLanguage(r); len(preferred) > 0 {
+		ids := make([]int64, len(movies))
+		for i, movie := range movies {
+			ids[i] = movie.ID
+		}
+
+		translations, err := app.models.Translations.GetAllForMovies(ids)
+		if err != nil {
+			return nil, app.graphqlServerError(r, err)
+		}
+
+		for _, movie := range movies {
+			data.Localize(movie, preferred, translations[movie.ID])
+		}
+	}
+
+	return &moviePage{movies: movies, metadata: metadata}, nil
+}
+
+// resolveMovie returns null, rather than an error, for a movie that
+// doesn't exist
+func (app *application) resolveMovie(p graphql.ResolveParams) (any, error) {
+	r := gqlContext(p).r
+
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil || id < 1 {
+		return nil, nil
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, nil
+		default:
+			return nil, app.graphqlServerError(r, err)
+		}
+	}
+
+	translations, err := app.models.Translations.GetAllForMovie(movie.ID)
+	if err != nil {
+		return nil, app.graphqlServerError(r, err)
+	}
+
+	data.Localize(movie, app.readAcceptLanguage(r), translations)
+
+	return movie, nil
+}
+
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (any, error) {
+	r := gqlContext(p).r
+
+	movie := &data.Movie{Status: data.MovieStatusReleased}
+
+	v := validator.New()
+
+	if err := app.applyMovieInput(movie, p.Args["input"].(map[string]any), v); err != nil {
+		return nil, app.graphqlServerError(r, err)
+	}
+
+	if !v.Valid() {
+		return nil, app.graphqlFailedValidation(r, v)
+	}
+
+	err := app.models.Movies.Insert(movie)
+	if err != nil {
+		return nil, app.graphqlServerError(r, err)
+	}
+
+	return movie, nil
+}
+
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (any, error) {
+	r := gqlContext(p).r
+
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil || id < 1 {
+		return nil, app.graphqlNotFound(r)
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, app.graphqlNotFound(r)
+		default:
+			return nil, app.graphqlServerError(r, err)
+		}
+	}
+
+	if version, ok := p.Args["version"].(int); ok && int32(version) != movie.Version {
+		return nil, app.graphqlEditConflict(r)
+	}
+
+	v := validator.New()
+
+	if err := app.applyMovieInput(movie, p.Args["input"].(map[string]any), v); err != nil {
+		return nil, app.graphqlServerError(r, err)
+	}
+
+	if !v.Valid() {
+		return nil, app.graphqlFailedValidation(r, v)
+	}
+
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, app.graphqlEditConflict(r)
+		default:
+			return nil, app.graphqlServerError(r, err)
+		}
+	}
+
+	return movie, nil
+}
+
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (any, error) {
+	r := gqlContext(p).r
+
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil || id < 1 {
+		return nil, app.graphqlNotFound(r)
+	}
+
+	err = app.deleteMovie(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, app.graphqlNotFound(r)
+		default:
+			return nil, app.graphqlServerError(r, err)
+		}
+	}
+
+	return true, nil
+}
+
+// applyMovieInput copies the fields given in a MovieInput onto movie and
+// validates the result the same way POST and PATCH /v1/movies do. Fields
+// that aren't in input are left alone. input is keyed by the GraphQL field
+// names, the gRPC service converts its MovieInput with movieInputMap
+func (app *application) applyMovieInput(movie *data.Movie, input map[string]any, v *validator.Validator) error {
+	if title, ok := input["title"].(string); ok {
+		movie.Title = title
+	}
+
+	if year, ok := input["year"].(int); ok {
+		movie.Year = int32(year)
+	}
+
+	if runtime, ok := input["runtime"].(int); ok {
+		movie.Runtime = data.Runtime(runtime)
+	}
+
+	if genres, ok := input["genres"].([]any); ok {
+		movie.Genres = make([]string, len(genres))
+		for i, genre := range genres {
+			movie.Genres[i] = genre.(string)
+		}
+	}
+
+	if overview, ok := input["overview"].(string); ok {
+		movie.Overview = overview
+	}
+
+	if tagline, ok := input["tagline"].(string); ok {
+		movie.Tagline = tagline
+	}
+
+	if status, ok := input["status"].(string); ok {
+		movie.Status = status
+	}
+
+	if releaseDates, ok := input["releaseDates"].([]any); ok {
+		movie.ReleaseDates = make(data.ReleaseDates, len(releaseDates))
+		for i, value := range releaseDates {
+			releaseDate := value.(map[string]any)
+			movie.ReleaseDates[i].Country, _ = releaseDate["country"].(string)
+			movie.ReleaseDates[i].Date, _ = releaseDate["date"].(string)
+			movie.ReleaseDates[i].Certification, _ = releaseDate["certification"].(string)
+		}
+	}
+
+	genres, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		return err
+	}
+
+	movie.Genres = genres.Canonicalize(movie.Genres)
+
+	// An empty string clears the original language
+	if language, ok := input["originalLanguage"].(string); ok {
+		movie.OriginalLanguage = ""
+
+		if language != "" {
+			movie.OriginalLanguage = data.ParseLanguage(v, "original_language", language)
+		}
+	}
+
+	data.ValidateMovie(v, movie, genres)
+
+	return nil
+}
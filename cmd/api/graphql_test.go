@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGraphQLMutationsNeedPermission(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"createMovie", `mutation { createMovie(input: {title: "Alien"}) { id } }`},
+		{"updateMovie", `mutation { updateMovie(id: "1", input: {title: "Alien"}) { id } }`},
+		{"deleteMovie", `mutation { deleteMovie(id: "1") }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			var body struct {
+				Errors []struct {
+					Extensions struct {
+						Code string `json:"code"`
+					} `json:"extensions"`
+				} `json:"errors"`
+			}
+
+			status := sendJSON(t, app, http.MethodPost, "/v1/graphql", map[string]string{"query": tt.query}, &body)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d; want %d", status, http.StatusOK)
+			}
+
+			if len(body.Errors) != 1 || body.Errors[0].Extensions.Code != "authentication_required" {
+				t.Errorf("got errors %+v; want one authentication_required", body.Errors)
+			}
+		})
+	}
+}
+
+func TestCheckGraphQLLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		code      string
+	}{
+		{"shallow", `{ movie(id: "1") { title } }`, nil, ""},
+		{"too deep", `{ movie(id: "1") { credits { person { name } } } }`, nil, "query_too_deep"},
+		{"too deep in a fragment", `{ movie(id: "1") { ...cast } } fragment cast on Movie { credits { person { name } } }`, nil, "query_too_deep"},
+		{"small page", `{ movies(pageSize: 10) { movies { title year } } }`, nil, ""},
+		// The page's movies are the same list as the query's, 20 by default
+		{"default page", `{ movies { movies { title } } }`, nil, ""},
+		{"large page", `{ movies(pageSize: 50) { movies { title year } } }`, nil, "query_too_complex"},
+		{"large page in a variable", `query($n: Int) { movies(pageSize: $n) { movies { title year } } }`, map[string]any{"n": 50}, "query_too_complex"},
+		{"introspection", `{ __schema { types { fields { type { name } } } } }`, nil, ""},
+		{"doesn't parse", `{ movie(`, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.graphql.maxDepth = 3
+			app.config.graphql.maxComplexity = 100
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/graphql", nil)
+
+			err := app.checkGraphQLLimits(r, graphqlRequest{Query: tt.query, Variables: tt.variables})
+
+			var got string
+			if err != nil {
+				got = err.code
+			}
+
+			if got != tt.code {
+				t.Errorf("got %q (%v); want %q", got, err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/i18n"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	moviesv1 "github.com/jim-at-jibba/greenlight/proto/movies/v1"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer returns the gRPC server for the movie service. Watch streams
+// end when ctx is cancelled, otherwise they would hold up GracefulStop
+func (app *application) grpcServer(ctx context.Context) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(app.grpcRecoverUnary),
+		grpc.ChainStreamInterceptor(app.grpcRecoverStream),
+	)
+
+	moviesv1.RegisterMovieServiceServer(srv, &movieServer{app: app, shutdown: ctx.Done()})
+
+	return srv
+}
+
+// grpcRecoverUnary is recoverPanic for unary calls
+func (app *application) grpcRecoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = app.grpcServerError(ctx, fmt.Errorf("%s", p))
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// grpcRecoverStream is recoverPanic for streaming calls
+func (app *application) grpcRecoverStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = app.grpcServerError(ss.Context(), fmt.Errorf("%s", p))
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+// grpcLocalizer is localizer for gRPC calls, which send Accept-Language as
+// metadata
+func (app *application) grpcLocalizer(ctx context.Context) *i18n.Localizer {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	tags, _, err := language.ParseAcceptLanguage(strings.Join(md.Get("accept-language"), ","))
+	if err != nil {
+		return app.i18n.Localizer()
+	}
+
+	return app.i18n.Localizer(tags...)
+}
+
+func (app *application) grpcServerError(ctx context.Context, err error) error {
+	method, _ := grpc.Method(ctx)
+
+	app.logger.PrintError(err, map[string]string{
+		"grpc_method": method,
+	})
+
+	message := app.grpcLocalizer(ctx).Message([]string{"error.server_error"}, nil, "the server encountered a problem and could not process your request")
+	return status.Error(codes.Internal, message)
+}
+
+func (app *application) grpcNotFound(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.not_found"}, nil, "the request resource could not be found")
+	return status.Error(codes.NotFound, message)
+}
+
+func (app *application) grpcEditConflict(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.edit_conflict"}, nil, "unable to update the record due to an edit conflict, please try again")
+	return status.Error(codes.Aborted, message)
+}
+
+func (app *application) grpcInvalidAuthenticationToken(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.invalid_authentication_token"}, nil, "invalid or missing authentication token")
+	return status.Error(codes.Unauthenticated, message)
+}
+
+func (app *application) grpcAuthenticationRequired(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.authentication_required"}, nil, "you must be authenticated to access this resource")
+	return status.Error(codes.Unauthenticated, message)
+}
+
+func (app *application) grpcNotPermitted(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.not_permitted"}, nil, "you do not have permission to access this resource")
+	return status.Error(codes.PermissionDenied, message)
+}
+
+func (app *application) grpcShuttingDown(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.shutting_down"}, nil, "the server is shutting down")
+	return status.Error(codes.Unavailable, message)
+}
+
+func (app *application) grpcFeedOverflow(ctx context.Context) error {
+	message := app.grpcLocalizer(ctx).Message([]string{"error.feed_overflow"}, nil, "too far behind the change feed")
+	return status.Error(codes.ResourceExhausted, message)
+}
+
+// grpcRequirePermission is authenticate and requirePermission for gRPC
+// calls, which send the token as "authorization: Bearer <token>" metadata
+func (app *application) grpcRequirePermission(ctx context.Context, code string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 {
+		return app.grpcAuthenticationRequired(ctx)
+	}
+
+	headerParts := strings.Split(authorization[0], " ")
+	if len(authorization) != 1 || len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return app.grpcInvalidAuthenticationToken(ctx)
+	}
+
+	token := headerParts[1]
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		return app.grpcInvalidAuthenticationToken(ctx)
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.grpcInvalidAuthenticationToken(ctx)
+		default:
+			return app.grpcServerError(ctx, err)
+		}
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return app.grpcServerError(ctx, err)
+	}
+
+	if !permissions.Include(code) {
+		return app.grpcNotPermitted(ctx)
+	}
+
+	return nil
+}
+
+// grpcFailedValidation returns INVALID_ARGUMENT with a field violation for
+// each error in v, the gRPC version of failedValidationResponse
+func (app *application) grpcFailedValidation(ctx context.Context, v *validator.Validator) error {
+	l := app.grpcLocalizer(ctx)
+	v = translateValidator(l, v)
+
+	keys := make([]string, 0, len(v.Fields))
+	for key := range v.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	details := &errdetails.BadRequest{}
+
+	for _, key := range keys {
+		for _, err := range v.Fields[key] {
+			details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       key,
+				Description: err.Message,
+			})
+		}
+	}
+
+	st := status.New(codes.InvalidArgument, l.Message([]string{"error.failed_validation"}, nil, "one or more fields failed validation"))
+
+	withDetails, err := st.WithDetails(details)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// movieServer implements moviesv1.MovieServiceServer on top of the same
+// models and validation as the REST handlers
+type movieServer struct {
+	moviesv1.UnimplementedMovieServiceServer
+	app      *application
+	shutdown <-chan struct{}
+}
+
+func (s *movieServer) GetMovie(ctx context.Context, req *moviesv1.GetMovieRequest) (*moviesv1.Movie, error) {
+	if req.GetId() < 1 {
+		return nil, s.app.grpcNotFound(ctx)
+	}
+
+	movie, err := s.app.models.Movies.Get(req.GetId())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, s.app.grpcNotFound(ctx)
+		default:
+			return nil, s.app.grpcServerError(ctx, err)
+		}
+	}
+
+	return movieProto(movie), nil
+}
+
+// ListMovies builds the query string GET /v1/movies would get so the
+// filters are read and validated by readMovieListQuery, the same as the
+// GraphQL movies query
+func (s *movieServer) ListMovies(ctx context.Context, req *moviesv1.ListMoviesRequest) (*moviesv1.ListMoviesResponse, error) {
+	qs := url.Values{}
+
+	set := func(key, value string) {
+		if value != "" {
+			qs.Set(key, value)
+		}
+	}
+
+	set("title", req.GetTitle())
+	set("genres", strings.Join(req.GetGenres(), ","))
+	set("status", req.GetStatus())
+	set("original_language", req.GetOriginalLanguage())
+	set("country", req.GetCountry())
+	set("certification", req.GetCertification())
+	set("released_from", req.GetReleasedFrom())
+	set("released_to", req.GetReleasedTo())
+	set("sort", req.GetSort())
+
+	if req.GetPersonId() != 0 {
+		qs.Set("person_id", strconv.FormatInt(req.GetPersonId(), 10))
+	}
+
+	if req.GetCollectionId() != 0 {
+		qs.Set("collection_id", strconv.FormatInt(req.GetCollectionId(), 10))
+	}
+
+	if req.GetPage() != 0 {
+		qs.Set("page", strconv.Itoa(int(req.GetPage())))
+	}
+
+	if req.GetPageSize() != 0 {
+		qs.Set("page_size", strconv.Itoa(int(req.GetPageSize())))
+	}
+
+	v := validator.New()
+
+	criteria, filters := s.app.readMovieListQuery(qs, v)
+	if !v.Valid() {
+		return nil, s.app.grpcFailedValidation(ctx, v)
+	}
+
+	genres, err := s.app.models.Genres.Taxonomy()
+	if err != nil {
+		return nil, s.app.grpcServerError(ctx, err)
+	}
+
+	criteria.Genres = genres.Canonicalize(criteria.Genres)
+
+	movies, metadata, err := s.app.models.Movies.GetAll(criteria, filters)
+	if err != nil {
+		return nil, s.app.grpcServerError(ctx, err)
+	}
+
+	resp := &moviesv1.ListMoviesResponse{
+		Movies: make([]*moviesv1.Movie, len(movies)),
+		Metadata: &moviesv1.Metadata{
+			CurrentPage:  int32(metadata.CurrentPage),
+			PageSize:     int32(metadata.PageSize),
+			FirstPage:    int32(metadata.FirstPage),
+			LastPage:     int32(metadata.LastPage),
+			TotalRecords: int32(metadata.TotalRecords),
+		},
+	}
+
+	for i, movie := range movies {
+		resp.Movies[i] = movieProto(movie)
+	}
+
+	return resp, nil
+}
+
+func (s *movieServer) CreateMovie(ctx context.Context, req *moviesv1.CreateMovieRequest) (*moviesv1.Movie, error) {
+	if err := s.app.grpcRequirePermission(ctx, "movies:write"); err != nil {
+		return nil, err
+	}
+
+	movie := &data.Movie{Status: data.MovieStatusReleased}
+
+	v := validator.New()
+
+	if err := s.app.applyMovieInput(movie, movieInputMap(req.GetMovie()), v); err != nil {
+		return nil, s.app.grpcServerError(ctx, err)
+	}
+
+	if !v.Valid() {
+		return nil, s.app.grpcFailedValidation(ctx, v)
+	}
+
+	err := s.app.models.Movies.Insert(movie)
+	if err != nil {
+		return nil, s.app.grpcServerError(ctx, err)
+	}
+
+	return movieProto(movie), nil
+}
+
+func (s *movieServer) UpdateMovie(ctx context.Context, req *moviesv1.UpdateMovieRequest) (*moviesv1.Movie, error) {
+	if err := s.app.grpcRequirePermission(ctx, "movies:write"); err != nil {
+		return nil, err
+	}
+
+	if req.GetId() < 1 {
+		return nil, s.app.grpcNotFound(ctx)
+	}
+
+	movie, err := s.app.models.Movies.Get(req.GetId())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, s.app.grpcNotFound(ctx)
+		default:
+			return nil, s.app.grpcServerError(ctx, err)
+		}
+	}
+
+	if req.Version != nil && req.GetVersion() != movie.Version {
+		return nil, s.app.grpcEditConflict(ctx)
+	}
+
+	v := validator.New()
+
+	if err := s.app.applyMovieInput(movie, movieInputMap(req.GetMovie()), v); err != nil {
+		return nil, s.app.grpcServerError(ctx, err)
+	}
+
+	if !v.Valid() {
+		return nil, s.app.grpcFailedValidation(ctx, v)
+	}
+
+	err = s.app.models.Movies.Update(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, s.app.grpcEditConflict(ctx)
+		default:
+			return nil, s.app.grpcServerError(ctx, err)
+		}
+	}
+
+	return movieProto(movie), nil
+}
+
+func (s *movieServer) DeleteMovie(ctx context.Context, req *moviesv1.DeleteMovieRequest) (*moviesv1.DeleteMovieResponse, error) {
+	if err := s.app.grpcRequirePermission(ctx, "movies:write"); err != nil {
+		return nil, err
+	}
+
+	if req.GetId() < 1 {
+		return nil, s.app.grpcNotFound(ctx)
+	}
+
+	err := s.app.deleteMovie(req.GetId())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, s.app.grpcNotFound(ctx)
+		default:
+			return nil, s.app.grpcServerError(ctx, err)
+		}
+	}
+
+	return &moviesv1.DeleteMovieResponse{}, nil
+}
+
+// WatchMovies sends every movie change until the client goes away. A client
+// that can't keep up is cut off with RESOURCE_EXHAUSTED and the stream ends
+// with UNAVAILABLE when the server shuts down
+func (s *movieServer) WatchMovies(_ *moviesv1.WatchMoviesRequest, stream moviesv1.MovieService_WatchMoviesServer) error {
+	events, unsubscribe := s.app.events.subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+
+		case <-s.shutdown:
+			return s.app.grpcShuttingDown(stream.Context())
+
+		case event, ok := <-events:
+			if !ok {
+				return s.app.grpcFeedOverflow(stream.Context())
+			}
+
+			err := stream.Send(movieEventProto(event))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var movieEventTypes = map[string]moviesv1.MovieEvent_Type{
+	movieCreated: moviesv1.MovieEvent_TYPE_CREATED,
+	movieUpdated: moviesv1.MovieEvent_TYPE_UPDATED,
+	movieDeleted: moviesv1.MovieEvent_TYPE_DELETED,
+}
+
+func movieEventProto(event movieEvent) *moviesv1.MovieEvent {
+	pb := &moviesv1.MovieEvent{Type: movieEventTypes[event.Type], Id: event.ID}
+
+	if event.Movie != nil {
+		pb.Movie = movieProto(event.Movie)
+	}
+
+	return pb
+}
+
+func movieProto(movie *data.Movie) *moviesv1.Movie {
+	pb := &moviesv1.Movie{
+		Id:               movie.ID,
+		Title:            movie.Title,
+		Year:             movie.Year,
+		RuntimeMinutes:   int32(movie.Runtime),
+		Genres:           movie.Genres,
+		Version:          movie.Version,
+		AverageRating:    movie.AverageRating,
+		RatingCount:      movie.RatingCount,
+		Overview:         movie.Overview,
+		Tagline:          movie.Tagline,
+		OriginalLanguage: movie.OriginalLanguage,
+		Status:           movie.Status,
+	}
+
+	for _, releaseDate := range movie.ReleaseDates {
+		pb.ReleaseDates = append(pb.ReleaseDates, &moviesv1.ReleaseDate{
+			Country:       releaseDate.Country,
+			Date:          releaseDate.Date,
+			Certification: releaseDate.Certification,
+		})
+	}
+
+	return pb
+}
+
+// movieInputMap puts a MovieInput in the form applyMovieInput takes, only
+// the fields that are set end up in it
+func movieInputMap(in *moviesv1.MovieInput) map[string]any {
+	input := map[string]any{}
+
+	if in == nil {
+		return input
+	}
+
+	if in.Title != nil {
+		input["title"] = in.GetTitle()
+	}
+
+	if in.Year != nil {
+		input["year"] = int(in.GetYear())
+	}
+
+	if in.RuntimeMinutes != nil {
+		input["runtime"] = int(in.GetRuntimeMinutes())
+	}
+
+	if in.GetSetGenres() {
+		genres := make([]any, len(in.GetGenres()))
+		for i, genre := range in.GetGenres() {
+			genres[i] = genre
+		}
+		input["genres"] = genres
+	}
+
+	if in.Overview != nil {
+		input["overview"] = in.GetOverview()
+	}
+
+	if in.Tagline != nil {
+		input["tagline"] = in.GetTagline()
+	}
+
+	if in.OriginalLanguage != nil {
+		input["originalLanguage"] = in.GetOriginalLanguage()
+	}
+
+	if in.Status != nil {
+		input["status"] = in.GetStatus()
+	}
+
+	if in.GetSetReleaseDates() {
+		releaseDates := make([]any, len(in.GetReleaseDates()))
+		for i, releaseDate := range in.GetReleaseDates() {
+			releaseDates[i] = map[string]any{
+				"country":       releaseDate.GetCountry(),
+				"date":          releaseDate.GetDate(),
+				"certification": releaseDate.GetCertification(),
+			}
+		}
+		input["releaseDates"] = releaseDates
+	}
+
+	return input
+}
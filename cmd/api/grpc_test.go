@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	moviesv1 "github.com/jim-at-jibba/greenlight/proto/movies/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCMutationsNeedPermission(t *testing.T) {
+	app := newTestApplication(t)
+	srv := &movieServer{app: app}
+
+	calls := map[string]func(ctx context.Context) error{
+		"CreateMovie": func(ctx context.Context) error {
+			_, err := srv.CreateMovie(ctx, &moviesv1.CreateMovieRequest{})
+			return err
+		},
+		"UpdateMovie": func(ctx context.Context) error {
+			_, err := srv.UpdateMovie(ctx, &moviesv1.UpdateMovieRequest{Id: 1})
+			return err
+		},
+		"DeleteMovie": func(ctx context.Context) error {
+			_, err := srv.DeleteMovie(ctx, &moviesv1.DeleteMovieRequest{Id: 1})
+			return err
+		},
+	}
+
+	tokens := []struct {
+		name          string
+		authorization []string
+	}{
+		{"no token", nil},
+		{"not bearer", []string{"Basic " + strings.Repeat("A", 26)}},
+		{"malformed token", []string{"Bearer abc"}},
+	}
+
+	for name, call := range calls {
+		for _, tt := range tokens {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				if tt.authorization != nil {
+					ctx = metadata.NewIncomingContext(ctx, metadata.MD{"authorization": tt.authorization})
+				}
+
+				err := call(ctx)
+				if got := status.Code(err); got != codes.Unauthenticated {
+					t.Errorf("got %v (%v); want %v", got, err, codes.Unauthenticated)
+				}
+			})
+		}
+	}
+}
+
+// watchStream is the server side of a WatchMovies call. Send blocks until
+// release is closed so tests can let the subscriber fall behind
+type watchStream struct {
+	moviesv1.MovieService_WatchMoviesServer
+	ctx     context.Context
+	release chan struct{}
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(*moviesv1.MovieEvent) error {
+	<-s.release
+	return nil
+}
+
+func TestWatchMoviesErrorsAreLocalised(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{"accept-language": {"fr"}})
+
+	t.Run("shutting down", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		shutdown := make(chan struct{})
+		close(shutdown)
+
+		srv := &movieServer{app: app, shutdown: shutdown}
+
+		err := srv.WatchMovies(&moviesv1.WatchMoviesRequest{}, &watchStream{ctx: ctx, release: make(chan struct{})})
+
+		st := status.Convert(err)
+		if st.Code() != codes.Unavailable || st.Message() != "le serveur est en cours d'arrêt" {
+			t.Errorf("got %v %q; want %v in French", st.Code(), st.Message(), codes.Unavailable)
+		}
+	})
+
+	t.Run("too far behind", func(t *testing.T) {
+		app := newTestApplication(t)
+		srv := &movieServer{app: app, shutdown: make(chan struct{})}
+
+		stream := &watchStream{ctx: ctx, release: make(chan struct{})}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- srv.WatchMovies(&moviesv1.WatchMoviesRequest{}, stream)
+		}()
+
+		for subscribed := false; !subscribed; {
+			app.events.mu.Lock()
+			subscribed = len(app.events.subscribers) == 1
+			app.events.mu.Unlock()
+		}
+
+		// One event is held by the blocked Send, the buffer takes the next
+		// movieBufferSize and the one after that drops the subscriber
+		for i := 0; i < movieBufferSize+2; i++ {
+			app.events.publish(movieEvent{Seq: int64(i + 1), Type: movieUpdated, ID: 1})
+		}
+
+		close(stream.release)
+
+		st := status.Convert(<-errs)
+		if st.Code() != codes.ResourceExhausted || st.Message() != "trop en retard sur le flux des modifications" {
+			t.Errorf("got %v %q; want %v in French", st.Code(), st.Message(), codes.ResourceExhausted)
+		}
+	})
+}
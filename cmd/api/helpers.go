@@ -1,25 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/i18n"
 	"github.com/jim-at-jibba/greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
+	"golang.org/x/text/language"
 )
 
 func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+// For routes with more than one id in the path, e.g. /v1/movies/:id/credits/:credit_id
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
@@ -27,32 +40,298 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 
 type envelope map[string]any
 
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+// readRuntimeFormat picks the runtime format for the response from the
+// runtime_format query string parameter or the Runtime-Format header, in
+// that order. Anything unrecognised gets the default "<n> mins"
+func (app *application) readRuntimeFormat(r *http.Request) data.RuntimeFormat {
+	format := r.URL.Query().Get("runtime_format")
+	if format == "" {
+		format = r.Header.Get("Runtime-Format")
+	}
+
+	if !validator.PermittedValue(data.RuntimeFormat(format), data.RuntimeFormats...) {
+		return data.RuntimeMins
+	}
+
+	return data.RuntimeFormat(format)
+}
+
+// writeResponse encodes data in the format the client's Accept header asks
+// for, see encoding.go. When none of the formats are acceptable it writes a
+// 406 response instead
+func (app *application) writeResponse(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	js, err := app.marshalJSON(r, data)
+	if err != nil {
+		return err
+	}
+
+	f := negotiate(r, js)
+	if f == nil {
+		app.notAcceptableResponse(w, r)
+		return nil
+	}
+
+	return app.writeFormat(w, status, f, js, headers)
+}
+
+// streamResponse is writeResponse for long lists, such as a page of 100 movies.
+// JSON responses are streamed to w one item at a time rather than built up
+// in memory first, every other format goes through writeResponse. key names
+// the list in data, e.g. "movies". Once the first byte is written the status
+// can't change, so errors after that are logged rather than returned
+func (app *application) streamResponse(w http.ResponseWriter, r *http.Request, status int, data envelope, key string, headers http.Header) error {
+	list := reflect.ValueOf(data[key])
+
+	// A nil slice is null rather than [], leave that to encoding/json
+	if (list.Kind() == reflect.Slice && list.IsNil()) || negotiateFormat(r, func(*format) bool { return true }) != jsonFormat {
+		return app.writeResponse(w, r, status, data, headers)
+	}
+
+	if list.Kind() != reflect.Slice {
+		return fmt.Errorf("streamResponse: %q is a %T, not a slice", key, data[key])
+	}
+
+	pretty := app.prettyJSON(r)
+	runtimeFormat := app.readRuntimeFormat(r)
+
+	marshal := func(v any, prefix string) ([]byte, error) {
+		v = withRuntimeFormat(v, runtimeFormat)
+
+		if pretty {
+			return json.MarshalIndent(v, prefix, "\t")
+		}
+
+		return json.Marshal(v)
+	}
+
+	newline, indent, colon := "", "", ":"
+	if pretty {
+		newline, indent, colon = "\n", "\t", ": "
+	}
+
+	// Everything but the list is encoded up front, so that a value that
+	// can't be marshalled is still a 500. encoding/json sorts map keys, and
+	// the keys here are in the same order
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var head, tail bytes.Buffer
+	buf := &head
+
+	buf.WriteString("{")
+
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		name, _ := json.Marshal(k)
+		fmt.Fprintf(buf, "%s%s%s%s", newline, indent, name, colon)
+
+		if k == key {
+			buf.WriteString("[")
+			buf = &tail
+			continue
+		}
+
+		js, err := marshal(data[k], indent)
+		if err != nil {
+			return err
+		}
+		buf.Write(js)
+	}
+
+	buf.WriteString(newline + "}\n")
+
+	app.writeHeaders(w, status, jsonFormat, headers)
+
+	if _, err := w.Write(head.Bytes()); err != nil {
+		return nil
+	}
+
+	for i := 0; i < list.Len(); i++ {
+		js, err := marshal(list.Index(i).Interface(), indent+indent)
+		if err != nil {
+			app.logError(r, err)
+			return nil
+		}
+
+		sep := ","
+		if i == 0 {
+			sep = ""
+		}
+
+		if _, err := fmt.Fprintf(w, "%s%s%s%s", sep, newline, indent+indent, js); err != nil {
+			return nil
+		}
+	}
+
+	if list.Len() > 0 {
+		w.Write([]byte(newline + indent))
+	}
+
+	w.Write([]byte("]"))
+	w.Write(tail.Bytes())
+
+	return nil
+}
+
+// prettyJSON reports whether JSON responses should be indented. The pretty
+// query string parameter decides, and without it only development is
+func (app *application) prettyJSON(r *http.Request) bool {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		return pretty
+	}
+
+	return app.config.env == "development"
+}
+
+// marshalJSON is the JSON every response format starts from, with runtimes
+// in the format the client asked for
+func (app *application) marshalJSON(r *http.Request, data envelope) ([]byte, error) {
+	var js []byte
+	var err error
+
+	v := withRuntimeFormat(data, app.readRuntimeFormat(r))
+
 	// ("") no line prefix and tab indents ("\t")
-	js, err := json.MarshalIndent(data, "", "\t")
+	if app.prettyJSON(r) {
+		js, err = json.MarshalIndent(v, "", "\t")
+	} else {
+		js, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(js, '\n'), nil
+}
+
+func (app *application) writeFormat(w http.ResponseWriter, status int, f *format, js []byte, headers http.Header) error {
+	body, err := f.encode(js)
 	if err != nil {
 		return err
 	}
 
-	js = append(js, '\n')
+	app.writeHeaders(w, status, f, headers)
+	w.Write(body)
 
+	return nil
+}
+
+// writeHeaders sends the status and the headers every response in format f
+// shares, along with the caller's own
+func (app *application) writeHeaders(w http.ResponseWriter, status int, f *format, headers http.Header) {
 	for key, value := range headers {
+		// Vary is added to rather than replaced so that middleware such as
+		// compress can declare what it varies on too
+		if key == "Vary" {
+			w.Header()[key] = append(w.Header()[key], value...)
+			continue
+		}
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	// Callers can override the content type, e.g. for application/problem+json.
+	// Anything else already on w is replaced, a handler that set one for a
+	// stream can still fail with an error body
+	if headers.Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", f.mediaType)
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Runtime-Format")
 	w.WriteHeader(status)
-	w.Write(js)
+}
 
-	return nil
+// requestError is a readRequest error caused by the client. The message is in
+// English, key and params let badRequestHandler localise it
+type requestError struct {
+	key     string
+	params  map[string]any
+	message string
+}
+
+func newRequestError(key string, params map[string]any, format string, args ...any) error {
+	return &requestError{key: key, params: params, message: fmt.Sprintf(format, args...)}
+}
+
+func (e *requestError) Error() string {
+	return e.message
 }
 
-func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+// readRequest decodes the request body into dst using its Content-Type. A
+// body without one is taken to be JSON. An unsupported Content-Type returns
+// errUnsupportedMediaType, and an unsupported Content-Encoding
+// errUnsupportedContentEncoding, which badRequestHandler turns into a 415
+func (app *application) readRequest(w http.ResponseWriter, r *http.Request, dst any) error {
 	// Use http.MaxBtesReader() to limit the size of the request body to 1MB
 	maxBytes := 1_048_576
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 
-	dec := json.NewDecoder(r.Body)
+	// A compressed body is limited both before and after it's decompressed,
+	// so a small gzip bomb can't expand past maxBytes
+	decoded, release, err := decompressBody(r)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	r.Body = http.MaxBytesReader(w, io.NopCloser(decoded), int64(maxBytes))
+
+	f := jsonFormat
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return errUnsupportedMediaType
+		}
+
+		f = nil
+
+		for _, candidate := range formats {
+			if candidate.decode != nil && (mediaType == candidate.mediaType || contains(candidate.aliases, mediaType)) {
+				f = candidate
+			}
+		}
+
+		if f == nil {
+			return errUnsupportedMediaType
+		}
+	}
+
+	if f == jsonFormat {
+		return decodeJSON(r.Body, dst)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return newRequestError("too_large", map[string]any{"limit": maxBytesError.Limit},
+				"body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+
+		return err
+	}
+
+	if len(body) == 0 {
+		return newRequestError("empty", nil, "body must not be empty")
+	}
+
+	js, err := f.decode(body, dst)
+	if err != nil {
+		return err
+	}
+
+	return decodeJSON(bytes.NewReader(js), dst)
+}
+
+func decodeJSON(body io.Reader, dst any) error {
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 
 	// Decode request bodt into destination
@@ -67,29 +346,34 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 
 		switch {
 		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at charater %d)", syntaxError.Offset)
+			return newRequestError("json_syntax", map[string]any{"offset": syntaxError.Offset},
+				"body contains badly-formed JSON (at charater %d)", syntaxError.Offset)
 
 		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+			return newRequestError("json_eof", nil, "body contains badly-formed JSON")
 
 		// UnmarshalTypeError relate to JSON value being wrong type for
 		// target destination
 		case errors.As(err, &unmarshalTypeError):
 			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+				return newRequestError("json_type", map[string]any{"field": unmarshalTypeError.Field},
+					"body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
 			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+			return newRequestError("json_type_offset", map[string]any{"offset": unmarshalTypeError.Offset},
+				"body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
 
 		// io.EOF will be returned if the request body is empty
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return newRequestError("empty", nil, "body must not be empty")
 
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+			return newRequestError("unknown_key", map[string]any{"key": strings.Trim(fieldName, `"`)},
+				"body contains unknown key %s", fieldName)
 
 		case errors.As(err, &maxBytesError):
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+			return newRequestError("too_large", map[string]any{"limit": maxBytesError.Limit},
+				"body must not be larger than %d bytes", maxBytesError.Limit)
 
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
@@ -102,7 +386,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 
 	err = dec.Decode(&struct{}{})
 	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
+		return newRequestError("multiple_values", nil, "body must only contain a single JSON value")
 	}
 
 	return nil
@@ -139,9 +423,57 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 
 	i, err := strconv.Atoi(int)
 	if err != nil {
-		v.AddError(key, "must be an integer value")
+		v.Add(key, validator.NewError(validator.CodeFormat, "must be an integer value", validator.Params{"format": "integer"}))
 		return defaultValue
 	}
 
 	return i
 }
+
+// Dates are sent as YYYY-MM-DD. Returns nil and records a validation error
+// if the value can't be parsed
+func (app *application) readDate(value string, key string, v *validator.Validator) *time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		v.Add(key, validator.NewError(validator.CodeFormat, "must be a date in the format YYYY-MM-DD", validator.Params{"format": "2006-01-02"}))
+		return nil
+	}
+
+	return &t
+}
+
+// readAcceptLanguage returns the client's language preferences, most
+// preferred first. A missing or malformed header means no preference and the
+// original titles are served
+func (app *application) readAcceptLanguage(r *http.Request) []language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil {
+		return nil
+	}
+
+	return tags
+}
+
+// localizer returns the message catalog that best matches the client's
+// Accept-Language header
+func (app *application) localizer(r *http.Request) *i18n.Localizer {
+	return app.i18n.Localizer(app.readAcceptLanguage(r)...)
+}
+
+// background runs fn in a goroutine that is tracked by app.wg and can't take
+// the whole server down if it panics
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		fn()
+	}()
+}
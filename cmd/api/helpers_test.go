@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+// testMoviePage is what listMovieHander sends for a full page of movies
+func testMoviePage(app *application, r *http.Request, n int) envelope {
+	movies := make([]any, n)
+
+	for i := range movies {
+		id := int64(i + 1)
+
+		movies[i] = movieResource{
+			Movie: &data.Movie{
+				ID:               id,
+				Title:            fmt.Sprintf("Movie %d: \"quoted\" & <escaped>", id),
+				Year:             int32(1950 + i),
+				Runtime:          data.Runtime(80 + i),
+				Genres:           []string{"drama", "comedy"},
+				Version:          int32(i%3 + 1),
+				AverageRating:    float64(i%10) / 2,
+				RatingCount:      int32(i * 7),
+				ExternalIDs:      data.ExternalIDs{"imdb": fmt.Sprintf("tt%07d", id)},
+				Poster:           data.PosterURLs{"w185": fmt.Sprintf("/v1/blobs/posters/%d/w185", id)},
+				Overview:         "A movie about 90 mins long, or so it says",
+				Tagline:          "Coming soon",
+				OriginalLanguage: "en",
+				Status:           "released",
+				ReleaseDates:     data.ReleaseDates{{Country: "GB", Date: "2001-02-03", Certification: "15"}},
+			},
+			Links: movieLinks(id),
+		}
+	}
+
+	metadata := data.Metadata{CurrentPage: 1, PageSize: n, FirstPage: 1, LastPage: 5, TotalRecords: 5 * n}
+
+	return envelope{"movies": movies, "metadata": app.pageLinks(r, metadata)}
+}
+
+func TestStreamResponseMatchesWriteResponse(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name   string
+		target string
+		movies int
+	}{
+		{name: "compact", target: "/v1/movies?page_size=100", movies: 100},
+		{name: "pretty", target: "/v1/movies?page_size=100&pretty=true", movies: 100},
+		{name: "runtime format", target: "/v1/movies?page_size=100&runtime_format=hm", movies: 100},
+		{name: "one movie", target: "/v1/movies?pretty=true", movies: 1},
+		{name: "no movies", target: "/v1/movies", movies: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			env := testMoviePage(app, r, tt.movies)
+
+			written := httptest.NewRecorder()
+			err := app.writeResponse(written, r, http.StatusOK, env, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			streamed := httptest.NewRecorder()
+			err = app.streamResponse(streamed, r, http.StatusOK, env, "movies", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if streamed.Code != written.Code {
+				t.Errorf("got status %d, want %d", streamed.Code, written.Code)
+			}
+
+			for _, name := range []string{"Content-Type", "Vary"} {
+				if got, want := streamed.Header().Values(name), written.Header().Values(name); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("got %s %q, want %q", name, got, want)
+				}
+			}
+
+			if !bytes.Equal(streamed.Body.Bytes(), written.Body.Bytes()) {
+				t.Errorf("bodies differ\nstreamed: %s\nwritten:  %s", streamed.Body, written.Body)
+			}
+		})
+	}
+}
+
+func benchmarkMoviePage(b *testing.B, respond func(app *application, w http.ResponseWriter, r *http.Request, env envelope) error) {
+	app := newTestApplication(b)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies?page_size=100", nil)
+	env := testMoviePage(app, r, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+
+		err := respond(app, w, r, env)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.SetBytes(int64(w.Body.Len()))
+	}
+}
+
+func BenchmarkWriteResponse(b *testing.B) {
+	benchmarkMoviePage(b, func(app *application, w http.ResponseWriter, r *http.Request, env envelope) error {
+		return app.writeResponse(w, r, http.StatusOK, env, nil)
+	})
+}
+
+func BenchmarkStreamResponse(b *testing.B) {
+	benchmarkMoviePage(b, func(app *application, w http.ResponseWriter, r *http.Request, env envelope) error {
+		return app.streamResponse(w, r, http.StatusOK, env, "movies", nil)
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/metadata"
+)
+
+// startJobs kicks off the periodic background jobs. They stop when ctx is
+// cancelled, which serve() does at the start of graceful shutdown
+func (app *application) startJobs(ctx context.Context) {
+	if len(app.providers) > 0 && app.config.metadata.interval > 0 {
+		app.background(func() {
+			app.runEvery(ctx, app.config.metadata.interval, "metadata enrichment", app.enrichMetadata)
+		})
+	}
+
+	if app.config.similar.interval > 0 {
+		app.background(func() {
+			app.runEvery(ctx, app.config.similar.interval, "similarity refresh", app.refreshSimilarities)
+		})
+	}
+
+	app.background(func() {
+		app.feedMovieEvents(ctx)
+	})
+
+	if app.config.events.retention > 0 {
+		app.background(func() {
+			app.runEvery(ctx, time.Hour, "movie event pruning", app.pruneMovieEvents)
+		})
+	}
+}
+
+// runEvery runs fn straight away and then once every interval
+func (app *application) runEvery(ctx context.Context, interval time.Duration, name string, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := fn(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			app.logger.PrintError(err, map[string]string{"job": name})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) enrichMetadata(ctx context.Context) error {
+	enricher := &metadata.Enricher{
+		Models:    app.models,
+		Providers: app.providers,
+	}
+
+	result, err := enricher.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("metadata enrichment complete", map[string]string{
+		"checked":  strconv.Itoa(result.Checked),
+		"enriched": strconv.Itoa(result.Enriched),
+	})
+
+	return nil
+}
+
+func (app *application) refreshSimilarities(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	rows, err := app.models.Similarities.Refresh(ctx, app.config.similar.weights, app.config.similar.keep)
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("similarity refresh complete", map[string]string{
+		"rows": strconv.FormatInt(rows, 10),
+	})
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonAPITypes maps envelope and field names to the JSON:API type of the
+// resources they hold. Objects with an id under one of these names become
+// resource objects, e.g. the movie in {"movie": {...}} or the credits
+// expanded into a movie
+var jsonAPITypes = map[string]string{
+	"movie":   "movies",
+	"movies":  "movies",
+	"credits": "credits",
+	"person":  "people",
+	"people":  "people",
+}
+
+// jsonAPIResources reports whether the envelope holds anything that can be
+// sent as JSON:API resource objects
+func jsonAPIResources(js []byte) bool {
+	v, err := decodeGeneric(js)
+	if err != nil {
+		return false
+	}
+
+	env, _ := v.(map[string]any)
+
+	for key, value := range env {
+		if _, ok := jsonAPITypes[key]; !ok {
+			continue
+		}
+
+		switch value := value.(type) {
+		case []any:
+			return true
+		case map[string]any:
+			return value["id"] != nil
+		}
+	}
+
+	return false
+}
+
+// encodeJSONAPI turns an envelope into a JSON:API document. The resources
+// go in data, related resources in included, the links from the metadata
+// become the document's links and everything else goes in meta
+func encodeJSONAPI(js []byte) ([]byte, error) {
+	v, err := decodeGeneric(js)
+	if err != nil {
+		return nil, err
+	}
+
+	env, _ := v.(map[string]any)
+
+	doc := map[string]any{"jsonapi": map[string]any{"version": "1.1"}}
+	meta := map[string]any{}
+	inc := &included{seen: make(map[string]bool)}
+
+	for key, value := range env {
+		typ, ok := jsonAPITypes[key]
+
+		switch {
+		case ok:
+			if list, isList := value.([]any); isList {
+				data := make([]any, 0, len(list))
+				for _, item := range list {
+					data = append(data, inc.resource(typ, item))
+				}
+				doc["data"] = data
+			} else {
+				doc["data"] = inc.resource(typ, value)
+			}
+
+		case key == "metadata":
+			page, _ := value.(map[string]any)
+			if pageLinks, ok := page["links"]; ok {
+				doc["links"] = pageLinks
+				delete(page, "links")
+			}
+			if len(page) > 0 {
+				meta["page"] = page
+			}
+
+		default:
+			meta[key] = value
+		}
+	}
+
+	if len(meta) > 0 {
+		doc["meta"] = meta
+	}
+
+	if len(inc.resources) > 0 {
+		doc["included"] = inc.resources
+	}
+
+	// Indented JSON means the client asked for it pretty, see prettyJSON
+	if bytes.HasPrefix(js, []byte("{\n")) {
+		out, err := json.MarshalIndent(doc, "", "\t")
+		return append(out, '\n'), err
+	}
+
+	out, err := json.Marshal(doc)
+
+	return append(out, '\n'), err
+}
+
+// included collects the related resources of a document, each one once
+type included struct {
+	resources []any
+	seen      map[string]bool
+}
+
+// resource makes a JSON:API resource object out of obj. Fields that hold
+// resources of their own become relationships, with the resources added to
+// included
+func (inc *included) resource(typ string, value any) any {
+	obj, ok := value.(map[string]any)
+	if !ok || obj["id"] == nil {
+		return value
+	}
+
+	resource := map[string]any{"type": typ, "id": fmt.Sprint(obj["id"])}
+	attributes := map[string]any{}
+	relationships := map[string]any{}
+
+	for key, value := range obj {
+		switch key {
+		case "id":
+			continue
+		case "links":
+			resource["links"] = value
+			continue
+		}
+
+		relatedType, ok := jsonAPITypes[key]
+		if !ok {
+			attributes[key] = value
+			continue
+		}
+
+		switch value := value.(type) {
+		case []any:
+			identifiers := make([]any, 0, len(value))
+			for _, item := range value {
+				identifiers = append(identifiers, inc.add(relatedType, item))
+			}
+			relationships[key] = map[string]any{"data": identifiers}
+
+		case map[string]any:
+			relationships[key] = map[string]any{"data": inc.add(relatedType, value)}
+
+		default:
+			attributes[key] = value
+		}
+	}
+
+	if len(attributes) > 0 {
+		resource["attributes"] = attributes
+	}
+
+	if len(relationships) > 0 {
+		resource["relationships"] = relationships
+	}
+
+	return resource
+}
+
+// add puts a related resource in included, unless it's already there, and
+// returns its resource identifier
+func (inc *included) add(typ string, value any) any {
+	resource, ok := inc.resource(typ, value).(map[string]any)
+	if !ok {
+		return value
+	}
+
+	identifier := map[string]any{"type": typ, "id": resource["id"]}
+
+	if key := typ + ":" + fmt.Sprint(resource["id"]); !inc.seen[key] {
+		inc.seen[key] = true
+		inc.resources = append(inc.resources, resource)
+	}
+
+	return identifier
+}
+
+// decodeJSONAPI takes the attributes out of a JSON:API request document,
+// {"data": {"type": "movies", "attributes": {...}}}, for decodeJSON
+func decodeJSONAPI(body []byte, _ any) ([]byte, error) {
+	var doc struct {
+		Data *struct {
+			Type       string          `json:"type"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+
+	err := json.Unmarshal(body, &doc)
+	if err != nil || doc.Data == nil || doc.Data.Type == "" {
+		return nil, newRequestError("body_malformed", map[string]any{"format": "JSON:API"}, "body contains badly-formed JSON:API")
+	}
+
+	if len(doc.Data.Attributes) == 0 {
+		return []byte("{}"), nil
+	}
+
+	return doc.Data.Attributes, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+// links are hypermedia links keyed by relation, e.g. "self". The URLs are
+// relative to the API's own host, the same as the Location header
+type links map[string]string
+
+func movieLinks(id int64) links {
+	return links{
+		"self":       fmt.Sprintf("/v1/movies/%d", id),
+		"collection": "/v1/movies",
+	}
+}
+
+// movieResource is a movie as the API sends it, with its links alongside
+// the fields from data.Movie
+type movieResource struct {
+	*data.Movie
+	Links links `json:"links"`
+}
+
+// pageMetadata is data.Metadata with links to the other pages of the same
+// results
+type pageMetadata struct {
+	data.Metadata
+	Links links `json:"links,omitempty"`
+}
+
+// pageLinks adds first/prev/next/last links to metadata. They keep every
+// other query string parameter of r so filters and sorting carry over, and
+// prev and next are left out on the first and last pages
+func (app *application) pageLinks(r *http.Request, metadata data.Metadata) pageMetadata {
+	if metadata.TotalRecords == 0 {
+		return pageMetadata{Metadata: metadata}
+	}
+
+	page := func(n int) string {
+		qs := r.URL.Query()
+		qs.Set("page", strconv.Itoa(n))
+
+		return r.URL.Path + "?" + qs.Encode()
+	}
+
+	pl := links{
+		"first": page(metadata.FirstPage),
+		"last":  page(metadata.LastPage),
+	}
+
+	if metadata.CurrentPage > metadata.FirstPage {
+		pl["prev"] = page(metadata.CurrentPage - 1)
+	}
+
+	if metadata.CurrentPage < metadata.LastPage {
+		pl["next"] = page(metadata.CurrentPage + 1)
+	}
+
+	return pageMetadata{Metadata: metadata, Links: pl}
+}
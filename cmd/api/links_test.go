@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+func TestPageLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		metadata data.Metadata
+		want     links
+	}{
+		{
+			name:     "no results",
+			query:    "page=1",
+			metadata: data.Metadata{},
+			want:     nil,
+		},
+		{
+			name:     "only page",
+			query:    "",
+			metadata: data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 3},
+			want: links{
+				"first": "/v1/movies?page=1",
+				"last":  "/v1/movies?page=1",
+			},
+		},
+		{
+			name:     "first page",
+			query:    "page=1&page_size=2",
+			metadata: data.Metadata{CurrentPage: 1, PageSize: 2, FirstPage: 1, LastPage: 3, TotalRecords: 5},
+			want: links{
+				"first": "/v1/movies?page=1&page_size=2",
+				"next":  "/v1/movies?page=2&page_size=2",
+				"last":  "/v1/movies?page=3&page_size=2",
+			},
+		},
+		{
+			name:     "middle page keeps the filters",
+			query:    "title=alien&genres=horror,sci-fi&sort=-year&page=2&page_size=2",
+			metadata: data.Metadata{CurrentPage: 2, PageSize: 2, FirstPage: 1, LastPage: 3, TotalRecords: 5},
+			want: links{
+				"first": "/v1/movies?genres=horror%2Csci-fi&page=1&page_size=2&sort=-year&title=alien",
+				"prev":  "/v1/movies?genres=horror%2Csci-fi&page=1&page_size=2&sort=-year&title=alien",
+				"next":  "/v1/movies?genres=horror%2Csci-fi&page=3&page_size=2&sort=-year&title=alien",
+				"last":  "/v1/movies?genres=horror%2Csci-fi&page=3&page_size=2&sort=-year&title=alien",
+			},
+		},
+		{
+			name:     "last page",
+			query:    "page=3&page_size=2",
+			metadata: data.Metadata{CurrentPage: 3, PageSize: 2, FirstPage: 1, LastPage: 3, TotalRecords: 5},
+			want: links{
+				"first": "/v1/movies?page=1&page_size=2",
+				"prev":  "/v1/movies?page=2&page_size=2",
+				"last":  "/v1/movies?page=3&page_size=2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies?"+tt.query, nil)
+
+			got := app.pageLinks(r, tt.metadata)
+
+			if got.Metadata != tt.metadata {
+				t.Errorf("got metadata %+v; want %+v", got.Metadata, tt.metadata)
+			}
+
+			if !reflect.DeepEqual(got.Links, tt.want) {
+				t.Errorf("got links %v; want %v", got.Links, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovieResource(t *testing.T) {
+	resource := movieResource{Movie: &data.Movie{ID: 7, Title: "Alien"}, Links: movieLinks(7)}
+
+	js, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		ID    int64             `json:"id"`
+		Title string            `json:"title"`
+		Links map[string]string `json:"links"`
+	}
+
+	err = json.Unmarshal(js, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"self": "/v1/movies/7", "collection": "/v1/movies"}
+
+	if got.ID != 7 || got.Title != "Alien" || !reflect.DeepEqual(got.Links, want) {
+		t.Errorf("got %s", js)
+	}
+}
+
+func TestJSONAPIResources(t *testing.T) {
+	tests := []struct {
+		name string
+		js   string
+		want bool
+	}{
+		{"movie", `{"movie": {"id": 1, "title": "Alien"}}`, true},
+		{"movies", `{"movies": [], "metadata": {}}`, true},
+		{"person", `{"person": {"id": 1}}`, true},
+		{"movie without id", `{"movie": {"title": "Alien"}}`, false},
+		{"healthcheck", `{"status": "available"}`, false},
+		{"error", `{"error": "the request resource could not be found"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonAPIResources([]byte(tt.js)); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeJSONAPI(t *testing.T) {
+	tests := []struct {
+		name string
+		js   string
+		want string
+	}{
+		{
+			name: "single movie",
+			js:   `{"movie": {"id": 1, "title": "Alien", "year": 1979, "links": {"self": "/v1/movies/1"}}}`,
+			want: `{
+				"jsonapi": {"version": "1.1"},
+				"data": {
+					"type": "movies", "id": "1",
+					"attributes": {"title": "Alien", "year": 1979},
+					"links": {"self": "/v1/movies/1"}
+				}
+			}`,
+		},
+		{
+			name: "page of movies",
+			js:   `{"movies": [{"id": 1, "title": "Alien"}, {"id": 2, "title": "Aliens"}], "metadata": {"current_page": 1, "links": {"first": "/v1/movies?page=1"}}}`,
+			want: `{
+				"jsonapi": {"version": "1.1"},
+				"data": [
+					{"type": "movies", "id": "1", "attributes": {"title": "Alien"}},
+					{"type": "movies", "id": "2", "attributes": {"title": "Aliens"}}
+				],
+				"links": {"first": "/v1/movies?page=1"},
+				"meta": {"page": {"current_page": 1}}
+			}`,
+		},
+		{
+			name: "expanded credits",
+			js: `{"movies": [
+				{"id": 1, "credits": [{"id": 10, "role": "director", "person": {"id": 5, "name": "Ridley Scott"}}]},
+				{"id": 2, "credits": [{"id": 11, "role": "director", "person": {"id": 5, "name": "Ridley Scott"}}]}
+			]}`,
+			want: `{
+				"jsonapi": {"version": "1.1"},
+				"data": [
+					{"type": "movies", "id": "1", "relationships": {"credits": {"data": [{"type": "credits", "id": "10"}]}}},
+					{"type": "movies", "id": "2", "relationships": {"credits": {"data": [{"type": "credits", "id": "11"}]}}}
+				],
+				"included": [
+					{"type": "people", "id": "5", "attributes": {"name": "Ridley Scott"}},
+					{"type": "credits", "id": "10", "attributes": {"role": "director"}, "relationships": {"person": {"data": {"type": "people", "id": "5"}}}},
+					{"type": "credits", "id": "11", "attributes": {"role": "director"}, "relationships": {"person": {"data": {"type": "people", "id": "5"}}}}
+				]
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeJSONAPI([]byte(tt.js))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			have, err := decodeGeneric(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := decodeGeneric([]byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("got %s", got)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONAPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		invalid bool
+	}{
+		{"attributes", `{"data": {"type": "users", "attributes": {"name": "Alice"}}}`, `{"name": "Alice"}`, false},
+		{"no attributes", `{"data": {"type": "users"}}`, `{}`, false},
+		{"no type", `{"data": {"attributes": {"name": "Alice"}}}`, "", true},
+		{"no data", `{"name": "Alice"}`, "", true},
+		{"not json", `name=Alice`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeJSONAPI([]byte(tt.body), nil)
+
+			if tt.invalid {
+				var requestErr *requestError
+				if !errors.As(err, &requestErr) || requestErr.key != "body_malformed" {
+					t.Errorf("got error %v; want a body_malformed error", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONAPIRequest(t *testing.T) {
+	app := newTestApplication(t)
+
+	body := `{"data": {"type": "users", "attributes": {"name": "", "email": "alice@example.com", "password": "pa55word"}}}`
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/vnd.api+json")
+
+	w := httptest.NewRecorder()
+	app.routes().ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got status %d; want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+
+	if !strings.Contains(w.Body.String(), `"name": "must be provided"`) {
+		t.Errorf("got %s; want an error for the name attribute", w.Body)
+	}
+}
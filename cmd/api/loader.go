@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// loader batches lookups by id. GraphQL resolvers call load and get back a
+// thunk, graphql-go runs the thunks only once every field on the same level
+// of the query has been resolved, so the first thunk to run fetches the ids
+// of all of them with one query instead of one query each
+type loader[V any] struct {
+	mu      sync.Mutex
+	fetch   func(ids []int64) (map[int64]V, error)
+	onError func(err error) error
+	pending []int64
+	results map[int64]V
+	errors  map[int64]error
+}
+
+// newLoader returns a loader that gets values from fetch. A failed fetch is
+// passed through onError once and the result is the error of every id in
+// the batch
+func newLoader[V any](fetch func(ids []int64) (map[int64]V, error), onError func(err error) error) *loader[V] {
+	return &loader[V]{fetch: fetch, onError: onError, results: make(map[int64]V), errors: make(map[int64]error)}
+}
+
+// load queues id for the next batch and returns a thunk for its value. Ids
+// fetch doesn't return anything for come back as the zero value
+func (l *loader[V]) load(id int64) func() (any, error) {
+	l.mu.Lock()
+	_, fetched := l.results[id]
+	_, failed := l.errors[id]
+	if !fetched && !failed && !slices.Contains(l.pending, id) {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		return l.get(id)
+	}
+}
+
+func (l *loader[V]) get(id int64) (V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) > 0 {
+		ids := l.pending
+		l.pending = nil
+
+		fetched, err := l.fetch(ids)
+		if err != nil {
+			err = l.onError(err)
+
+			for _, id := range ids {
+				l.errors[id] = err
+			}
+		} else {
+			for _, id := range ids {
+				l.results[id] = fetched[id]
+			}
+		}
+	}
+
+	if err, ok := l.errors[id]; ok {
+		var zero V
+		return zero, err
+	}
+
+	return l.results[id], nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestLoaderBatchesLookups(t *testing.T) {
+	var calls [][]int64
+
+	l := newLoader(func(ids []int64) (map[int64]string, error) {
+		calls = append(calls, ids)
+		return map[int64]string{1: "one", 2: "two"}, nil
+	}, nil)
+
+	thunks := []func() (any, error){l.load(1), l.load(2), l.load(2), l.load(3)}
+
+	want := []any{"one", "two", "two", ""}
+
+	for i, thunk := range thunks {
+		got, err := thunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want[i] {
+			t.Errorf("thunk %d: got %q; want %q", i, got, want[i])
+		}
+	}
+
+	// Ids that have been fetched come from the results
+	if got, _ := l.load(1)(); got != "one" {
+		t.Errorf("got %q; want %q", got, "one")
+	}
+
+	if len(calls) != 1 || !slices.Equal(calls[0], []int64{1, 2, 3}) {
+		t.Errorf("got fetches %v; want one for [1 2 3]", calls)
+	}
+}
+
+func TestLoaderErrorReachesEveryThunk(t *testing.T) {
+	errFetch := errors.New("connection refused")
+	errClient := errors.New("the server encountered a problem")
+
+	var handled int
+
+	l := newLoader(func(ids []int64) (map[int64]string, error) {
+		return nil, errFetch
+	}, func(err error) error {
+		handled++
+		if !errors.Is(err, errFetch) {
+			t.Errorf("got %v; want the fetch error", err)
+		}
+		return errClient
+	})
+
+	thunks := []func() (any, error){l.load(1), l.load(2), l.load(3)}
+
+	for i, thunk := range thunks {
+		_, err := thunk()
+		if !errors.Is(err, errClient) {
+			t.Errorf("thunk %d: got error %v; want %v", i, err, errClient)
+		}
+	}
+
+	if handled != 1 {
+		t.Errorf("got onError called %d times; want once per batch", handled)
+	}
+}
+
+// graphql-go loses the extensions of errors returned from thunks,
+// withGraphQLExtensions has to put them back
+func TestLoaderErrorsKeepExtensions(t *testing.T) {
+	l := newLoader(func(ids []int64) (map[int64]string, error) {
+		return nil, errors.New("connection refused")
+	}, func(err error) error {
+		return &graphqlError{code: "server_error", message: "the server encountered a problem"}
+	})
+
+	item := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Item",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return l.load(p.Source.(int64)), nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"items": &graphql.Field{
+					Type: graphql.NewList(item),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return []int64{1, 2, 3}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: "{ items { name } }"})
+
+	errs := withGraphQLExtensions(result.Errors)
+	if len(errs) != 3 {
+		t.Fatalf("got %d errors; want one per item", len(errs))
+	}
+
+	for _, err := range errs {
+		if err.Extensions["code"] != "server_error" {
+			t.Errorf("got extensions %v for %q; want code server_error", err.Extensions, err.Message)
+		}
+	}
+}
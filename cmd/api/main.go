@@ -3,12 +3,19 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/i18n"
 	"github.com/jim-at-jibba/greenlight/internal/jsonlog"
+	"github.com/jim-at-jibba/greenlight/internal/metadata"
+	"github.com/jim-at-jibba/greenlight/internal/storage"
 	_ "github.com/lib/pq"
 )
 
@@ -36,12 +43,63 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	// Responses smaller than minBytes are sent uncompressed
+	compress struct {
+		enabled  bool
+		minBytes int
+	}
+	// The gRPC service listens on its own port, 0 turns it off
+	grpc struct {
+		port int
+	}
+	// Queries deeper than maxDepth, or that could return more than
+	// maxComplexity objects, are rejected before they run
+	graphql struct {
+		maxDepth      int
+		maxComplexity int
+	}
+	// Clients can resume the movie change feed from any event newer than
+	// retention
+	events struct {
+		retention time.Duration
+	}
+	metadata struct {
+		file     string
+		interval time.Duration
+	}
+	// With a refresh interval of 0 similar movies are scored on every request,
+	// otherwise they are read from the table the refresh job fills in
+	similar struct {
+		weights  data.SimilarityWeights
+		interval time.Duration
+		keep     int
+	}
+	// store is "fs" or "s3". With "fs" the API serves the files itself under
+	// /v1/blobs/ unless baseURL points somewhere else
+	blob struct {
+		store      string
+		dir        string
+		baseURL    string
+		maxBytes   int64
+		s3Endpoint string
+		s3Bucket   string
+		s3Region   string
+		s3Access   string
+		s3Secret   string
+	}
 }
 
+// wg tracks goroutines started with app.background() so that serve() can
+// wait for them to finish during graceful shutdown
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
+	config    config
+	logger    *jsonlog.Logger
+	models    data.Models
+	i18n      *i18n.Bundle
+	providers []metadata.Provider
+	blobs     storage.BlobStore
+	events    *movieBroker
+	wg        sync.WaitGroup
 }
 
 func main() {
@@ -49,6 +107,7 @@ func main() {
 
 	// Read value of env and port from cli flag into config struct
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
+	flag.IntVar(&cfg.grpc.port, "grpc-port", 4001, "gRPC server port (0 disables it)")
 	flag.StringVar(&cfg.env, "env", "development", "Environment(development|staging|production)")
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgresSQL dsn")
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgresSQL max open connections")
@@ -58,10 +117,43 @@ func main() {
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+
+	flag.BoolVar(&cfg.compress.enabled, "compress-enabled", true, "Enable response compression")
+	flag.IntVar(&cfg.compress.minBytes, "compress-min-bytes", 1024, "Smallest response body in bytes worth compressing")
+
+	flag.IntVar(&cfg.graphql.maxDepth, "graphql-max-depth", 8, "Deepest GraphQL query allowed")
+	flag.IntVar(&cfg.graphql.maxComplexity, "graphql-max-complexity", 10000, "Most objects a GraphQL query may ask for")
+
+	flag.DurationVar(&cfg.events.retention, "events-retention", 24*time.Hour, "How long movie change events are kept for clients to resume from (0 keeps them forever)")
+
+	flag.StringVar(&cfg.metadata.file, "metadata-file", "", "JSON file of external movie metadata (enrichment is off when empty)")
+	flag.DurationVar(&cfg.metadata.interval, "metadata-interval", time.Hour, "How often to enrich movies with missing metadata")
+
+	flag.Float64Var(&cfg.similar.weights.Genres, "similar-weight-genres", data.DefaultSimilarityWeights.Genres, "Weight of shared genres in movie similarity")
+	flag.Float64Var(&cfg.similar.weights.Year, "similar-weight-year", data.DefaultSimilarityWeights.Year, "Weight of release year proximity in movie similarity")
+	flag.Float64Var(&cfg.similar.weights.Credits, "similar-weight-credits", data.DefaultSimilarityWeights.Credits, "Weight of shared cast and crew in movie similarity")
+	flag.Float64Var(&cfg.similar.weights.Title, "similar-weight-title", data.DefaultSimilarityWeights.Title, "Weight of title similarity in movie similarity")
+	flag.DurationVar(&cfg.similar.interval, "similar-refresh-interval", 0, "How often to precompute similar movies (0 scores them on each request)")
+	flag.IntVar(&cfg.similar.keep, "similar-keep", 50, "Number of similar movies precomputed for each movie")
+
+	flag.StringVar(&cfg.blob.store, "blob-store", "fs", "Blob store for uploaded images (fs|s3)")
+	flag.StringVar(&cfg.blob.dir, "blob-dir", "./uploads", "Directory used by the fs blob store")
+	flag.StringVar(&cfg.blob.baseURL, "blob-base-url", "/v1/blobs", "Public URL prefix for stored blobs")
+	flag.Int64Var(&cfg.blob.maxBytes, "poster-max-bytes", 10_485_760, "Maximum size of an uploaded poster in bytes")
+	flag.StringVar(&cfg.blob.s3Endpoint, "s3-endpoint", "", "S3 compatible endpoint, e.g. http://localhost:9000")
+	flag.StringVar(&cfg.blob.s3Bucket, "s3-bucket", "", "S3 bucket name")
+	flag.StringVar(&cfg.blob.s3Region, "s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.blob.s3Access, "s3-access-key", os.Getenv("GREENLIGHT_S3_ACCESS_KEY"), "S3 access key")
+	flag.StringVar(&cfg.blob.s3Secret, "s3-secret-key", os.Getenv("GREENLIGHT_S3_SECRET_KEY"), "S3 secret key")
 	flag.Parse()
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
+	err := cfg.similar.weights.Validate()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -70,11 +162,34 @@ func main() {
 	defer db.Close()
 	logger.PrintInfo("database connection pool established", nil)
 
+	var providers []metadata.Provider
+
+	if cfg.metadata.file != "" {
+		providers, err = metadata.LoadFileProviders(cfg.metadata.file)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	blobs, err := openBlobStore(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	bundle, err := i18n.New()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	// Declare an instace of the application sturct
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:    cfg,
+		logger:    logger,
+		models:    data.NewModels(db),
+		i18n:      bundle,
+		providers: providers,
+		blobs:     blobs,
+		events:    newMovieBroker(),
 	}
 
 	err = app.serve()
@@ -112,3 +227,35 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func openBlobStore(cfg config) (storage.BlobStore, error) {
+	switch cfg.blob.store {
+	case "fs":
+		return &storage.FileSystemStore{Dir: cfg.blob.dir, BaseURL: cfg.blob.baseURL}, nil
+
+	case "s3":
+		if cfg.blob.s3Endpoint == "" || cfg.blob.s3Bucket == "" {
+			return nil, errors.New("-s3-endpoint and -s3-bucket are required with -blob-store=s3")
+		}
+
+		// Only use blob-base-url when it has been changed from the fs default,
+		// otherwise URLs point straight at the bucket
+		publicURL := ""
+		if cfg.blob.baseURL != "/v1/blobs" {
+			publicURL = cfg.blob.baseURL
+		}
+
+		return &storage.S3Store{
+			Endpoint:  cfg.blob.s3Endpoint,
+			Bucket:    cfg.blob.s3Bucket,
+			Region:    cfg.blob.s3Region,
+			AccessKey: cfg.blob.s3Access,
+			SecretKey: cfg.blob.s3Secret,
+			PublicURL: publicURL,
+			Client:    &http.Client{Timeout: 30 * time.Second},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.blob.store)
+	}
+}
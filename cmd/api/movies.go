@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/jim-at-jibba/greenlight/internal/data"
 	"github.com/jim-at-jibba/greenlight/internal/validator"
@@ -12,13 +13,18 @@ import (
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	// this struct is our target decode destination
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title            string            `json:"title"`
+		Year             int32             `json:"year"`
+		Runtime          data.Runtime      `json:"runtime"`
+		Genres           []string          `json:"genres"`
+		Overview         string            `json:"overview"`
+		Tagline          string            `json:"tagline"`
+		OriginalLanguage string            `json:"original_language"`
+		Status           string            `json:"status"`
+		ReleaseDates     data.ReleaseDates `json:"release_dates"`
 	}
 
-	err := app.readJSON(w, r, &input)
+	err := app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestHandler(w, r, err)
 		return
@@ -26,16 +32,38 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Validation now performed on Movie struct not input struct
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:        input.Title,
+		Year:         input.Year,
+		Runtime:      input.Runtime,
+		Genres:       input.Genres,
+		Overview:     input.Overview,
+		Tagline:      input.Tagline,
+		Status:       input.Status,
+		ReleaseDates: input.ReleaseDates,
 	}
 
+	if movie.Status == "" {
+		movie.Status = data.MovieStatusReleased
+	}
+
+	genres, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie.Genres = genres.Canonicalize(movie.Genres)
+
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+	// Stored in canonical form so the original_language filter matches
+	// however the client capitalised it
+	if input.OriginalLanguage != "" {
+		movie.OriginalLanguage = data.ParseLanguage(v, "original_language", input.OriginalLanguage)
+	}
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
@@ -50,7 +78,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"movie": movieResource{Movie: movie, Links: movieLinks(movie.ID)}}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -65,7 +93,19 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	fields := app.readFields(qs, "fields", data.MovieFields, v)
+	expand := app.readExpand(qs, "expand", movieExpansions, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id, fields...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -77,7 +117,24 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	translations, err := app.models.Translations.GetAllForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data.Localize(movie, app.readAcceptLanguage(r), translations)
+
+	sparse, err := app.sparseMovies([]*data.Movie{movie}, fields, expand)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": sparse[0]}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -107,15 +164,21 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+		Title            *string            `json:"title"`
+		Year             *int32             `json:"year"`
+		Runtime          *data.Runtime      `json:"runtime"`
+		Genres           []string           `json:"genres"`
+		Overview         *string            `json:"overview"`
+		Tagline          *string            `json:"tagline"`
+		OriginalLanguage *string            `json:"original_language"`
+		Status           *string            `json:"status"`
+		ReleaseDates     *data.ReleaseDates `json:"release_dates"`
 	}
 
-	err = app.readJSON(w, r, &input)
+	err = app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestHandler(w, r, err)
+		return
 	}
 
 	// If input.* has a value of nil then we know that no value was passed and we can ignore it
@@ -135,10 +198,44 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		movie.Genres = input.Genres // no need to dereference a slice
 	}
 
+	if input.Overview != nil {
+		movie.Overview = *input.Overview
+	}
+
+	if input.Tagline != nil {
+		movie.Tagline = *input.Tagline
+	}
+
+	if input.Status != nil {
+		movie.Status = *input.Status
+	}
+
+	// release_dates replaces the whole list, send [] to clear it
+	if input.ReleaseDates != nil {
+		movie.ReleaseDates = *input.ReleaseDates
+	}
+
+	genres, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie.Genres = genres.Canonicalize(movie.Genres)
+
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+	// An empty string clears the original language
+	if input.OriginalLanguage != nil {
+		movie.OriginalLanguage = ""
+
+		if *input.OriginalLanguage != "" {
+			movie.OriginalLanguage = data.ParseLanguage(v, "original_language", *input.OriginalLanguage)
+		}
+	}
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
@@ -154,7 +251,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movieResource{Movie: movie, Links: movieLinks(movie.ID)}}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -167,7 +264,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	err = app.deleteMovie(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -178,51 +275,124 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": "movie successfully deleted"}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movies": "movie successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 func (app *application) listMovieHander(w http.ResponseWriter, r *http.Request) {
-	// embed the new filters struct
-	var input struct {
-		Title  string
-		Genres []string
-		data.Filters
-	}
-
 	v := validator.New()
 
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
-	input.Filters.Page = app.readInt(qs, "page", 1, v)
-	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	criteria, filters := app.readMovieListQuery(qs, v)
+
+	fields := app.readFields(qs, "fields", data.MovieFields, v)
+	expand := app.readExpand(qs, "expand", movieExpansions, v)
 
-	if data.ValidateFilters(v, input.Filters); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+	// Filtering by "sci-fi" should find movies stored as "science-fiction"
+	genres, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	criteria.Genres = genres.Canonicalize(criteria.Genres)
+
+	movies, metadata, err := app.models.Movies.GetAll(criteria, filters, fields...)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if preferred := app.readAcceptLanguage(r); len(preferred) > 0 {
+		ids := make([]int64, len(movies))
+		for i, movie := range movies {
+			ids[i] = movie.ID
+		}
+
+		translations, err := app.models.Translations.GetAllForMovies(ids)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, movie := range movies {
+			data.Localize(movie, preferred, translations[movie.ID])
+		}
+	}
 
+	sparse, err := app.sparseMovies(movies, fields, expand)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
+
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+
+	err = app.streamResponse(w, r, http.StatusOK, envelope{"movies": sparse, "metadata": app.pageLinks(r, metadata)}, "movies", headers)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readMovieListQuery reads the filters, sorting and paging for a list of
+// movies from qs, with any problems added to v. The GraphQL movies query
+// goes through here too, so both take the same filters
+func (app *application) readMovieListQuery(qs url.Values, v *validator.Validator) (data.MovieCriteria, data.Filters) {
+	// embed the new filters struct
+	var input struct {
+		data.MovieCriteria
+		data.Filters
+	}
+
+	input.Title = app.readString(qs, "title", "")
+	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.PersonID = int64(app.readInt(qs, "person_id", 0, v))
+	input.CollectionID = int64(app.readInt(qs, "collection_id", 0, v))
+	input.Status = app.readString(qs, "status", "")
+	input.Country = app.readString(qs, "country", "")
+	input.Certification = app.readString(qs, "certification", "")
+
+	if language := app.readString(qs, "original_language", ""); language != "" {
+		input.OriginalLanguage = data.ParseLanguage(v, "original_language", language)
+	}
+
+	if from := app.readString(qs, "released_from", ""); from != "" {
+		input.ReleasedFrom = app.readDate(from, "released_from", v)
+	}
+
+	if to := app.readString(qs, "released_to", ""); to != "" {
+		input.ReleasedTo = app.readDate(to, "released_to", v)
+	}
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "rating", "-id", "-title", "-year", "-runtime", "-rating"}
+
+	v.CheckError(input.PersonID >= 0, "person_id", validator.NewError(validator.CodeMin, "must not be negative", validator.Params{"min": 0}))
+	v.CheckError(input.CollectionID >= 0, "collection_id", validator.NewError(validator.CodeMin, "must not be negative", validator.Params{"min": 0}))
+
+	if input.Status != "" {
+		v.CheckError(validator.PermittedValue(input.Status, data.MovieStatuses...), "status",
+			validator.NewError(validator.CodeOneOf, "must be one of announced or released", validator.Params{"values": data.MovieStatuses}))
+	}
+
+	if input.Country != "" {
+		v.CheckError(data.ValidCountry(input.Country), "country",
+			validator.NewError(validator.CodeFormat, "must be an ISO 3166-1 alpha-2 code such as US", validator.Params{"format": "iso3166-1"}))
+	}
+
+	data.ValidateFilters(v, input.Filters)
+
+	return input.MovieCriteria, input.Filters
 }
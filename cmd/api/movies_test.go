@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestListMoviesByPerson(t *testing.T) {
+	app := newTestApplication(t)
+	app.models = data.NewModels(testdb.New(t))
+
+	director := &data.Person{Name: "Ridley Scott"}
+	actor := &data.Person{Name: "Sigourney Weaver"}
+	nobody := &data.Person{Name: "Nobody"}
+
+	for _, person := range []*data.Person{director, actor, nobody} {
+		err := app.models.People.Insert(person)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	movies := map[string]int64{}
+
+	for _, title := range []string{"Alien", "Blade Runner", "Ghostbusters"} {
+		movie := &data.Movie{Title: title, Year: 1980, Runtime: 110, Genres: []string{"drama"}, Status: "released"}
+
+		err := app.models.Movies.Insert(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		movies[title] = movie.ID
+	}
+
+	credits := []*data.Credit{
+		{MovieID: movies["Alien"], PersonID: director.ID, Role: data.CreditRoleDirector},
+		{MovieID: movies["Blade Runner"], PersonID: director.ID, Role: data.CreditRoleDirector},
+		{MovieID: movies["Alien"], PersonID: actor.ID, Role: data.CreditRoleCast, Character: "Ripley"},
+		{MovieID: movies["Ghostbusters"], PersonID: actor.ID, Role: data.CreditRoleCast, Character: "Dana"},
+		// A second credit on the same movie mustn't list it twice
+		{MovieID: movies["Alien"], PersonID: director.ID, Role: data.CreditRoleCrew, Job: "Producer"},
+	}
+
+	for _, credit := range credits {
+		err := app.models.Credits.Insert(credit)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		personID int64
+		want     []string
+	}{
+		{"director", director.ID, []string{"Alien", "Blade Runner"}},
+		{"actor", actor.ID, []string{"Alien", "Ghostbusters"}},
+		{"no credits", nobody.ID, []string{}},
+		{"unknown person", nobody.ID + 1000, []string{}},
+		{"no filter", 0, []string{"Alien", "Blade Runner", "Ghostbusters"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Movies []struct {
+					Title string `json:"title"`
+				} `json:"movies"`
+			}
+
+			path := "/v1/movies?sort=title"
+			if tt.personID != 0 {
+				path += fmt.Sprintf("&person_id=%d", tt.personID)
+			}
+
+			code := getJSON(t, app, path, &body)
+			if code != http.StatusOK {
+				t.Fatalf("got status %d; want %d", code, http.StatusOK)
+			}
+
+			got := []string{}
+			for _, movie := range body.Movies {
+				got = append(got, movie.Title)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListMoviesInvalidPersonID(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, value := range []string{"-1", "abc"} {
+		t.Run(value, func(t *testing.T) {
+			var body struct {
+				Error map[string]any `json:"error"`
+			}
+
+			code := getJSON(t, app, "/v1/movies?person_id="+value, &body)
+			if code != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d", code, http.StatusUnprocessableEntity)
+			}
+
+			if _, ok := body.Error["person_id"]; !ok {
+				t.Errorf("got errors %v; want one for person_id", body.Error)
+			}
+		})
+	}
+}
+
+func TestListMoviesValidationIsLocalised(t *testing.T) {
+	tests := []struct {
+		query   string
+		field   string
+		code    string
+		message string
+	}{
+		{"person_id=-1", "person_id", "min", "ne doit pas être négatif"},
+		{"page=abc", "page", "format", "doit être un nombre entier"},
+		{"status=soon", "status", "one_of", "doit être announced ou released"},
+		{"country=usa", "country", "format", "doit être un code ISO 3166-1 alpha-2, par exemple US"},
+		{"released_from=2020-13-01", "released_from", "format", "doit être une date au format AAAA-MM-JJ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/movies?"+tt.query, nil)
+			req.Header.Set("Accept-Language", "fr")
+
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d", rr.Code, http.StatusUnprocessableEntity)
+			}
+
+			var body struct {
+				Fields map[string][]struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"fields"`
+			}
+
+			err := json.NewDecoder(rr.Body).Decode(&body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := body.Fields[tt.field]
+			if len(got) == 0 {
+				t.Fatalf("got fields %v; want an error for %s", body.Fields, tt.field)
+			}
+
+			if got[0].Code != tt.code || got[0].Message != tt.message {
+				t.Errorf("got %s %q; want %s %q", got[0].Code, got[0].Message, tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestMovieMutationsNeedAuthentication(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/movies"},
+		{http.MethodPatch, "/v1/movies/1"},
+		{http.MethodDelete, "/v1/movies/1"},
+		{http.MethodPost, "/v1/movies/1/credits"},
+		{http.MethodDelete, "/v1/movies/1/credits/1"},
+		{http.MethodPost, "/v1/people"},
+		{http.MethodPatch, "/v1/people/1"},
+		{http.MethodDelete, "/v1/people/1"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			var body map[string]any
+
+			status := sendJSON(t, app, route.method, route.path, map[string]any{}, &body)
+			if status != http.StatusUnauthorized {
+				t.Errorf("got status %d; want %d", status, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestUpdateMovieMalformedBody(t *testing.T) {
+	app := newTestApplication(t)
+
+	db := testdb.New(t)
+	app.models = data.NewModels(db)
+
+	token := newTestUser(t, app, db, "movies:write")
+
+	movie := &data.Movie{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Status: "released"}
+
+	err := app.models.Movies.Insert(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/movies/%d", movie.ID), strings.NewReader(`{"title": `))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d; want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	// The handler must stop after the 400, a second response would be
+	// appended to the body
+	var body map[string]any
+
+	err = json.Unmarshal(rr.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("got body %q: %v", rr.Body.String(), err)
+	}
+
+	got, err := app.models.Movies.Get(movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Version != movie.Version {
+		t.Errorf("got version %d; want the movie unchanged at %d", got.Version, movie.Version)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openapiSpec describes every route. It's written by hand, so a change to a
+// route, an envelope or a validation rule needs a matching change here
+//
+//go:embed openapi.json
+var openapiSpec []byte
+
+// docsPage renders the spec with Redoc. It loads the script from a CDN, so
+// it's only served in development
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Greenlight API</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+<redoc spec-url="/v1/openapi.json"></redoc>
+<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// openapiHandler serves the spec as it is, it's already JSON and isn't
+// wrapped in an envelope
+func (app *application) openapiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapiSpec)
+}
+
+func (app *application) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
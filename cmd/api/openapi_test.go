@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// undocumented routes are left out of the spec on purpose, the docs page
+// only exists in development and blobs are plain files
+var undocumented = map[string]bool{
+	"GET /v1/docs":             true,
+	"GET /v1/blobs/{filepath}": true,
+}
+
+// specParams are values path parameters are given when requests are sent,
+// they only have to get past the handlers' own checks to the database
+var specParams = map[string]string{
+	"provider":    "imdb",
+	"external_id": "tt0111161",
+	"language":    "fr",
+	"slug":        "abc",
+}
+
+var specParamRX = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// specRoutes returns every method and path in openapi.json, as "GET /v1/movies/{id}"
+func specRoutes(t *testing.T) []string {
+	t.Helper()
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	err := json.Unmarshal(openapiSpec, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []string
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "post", "put", "patch", "delete":
+				routes = append(routes, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(routes)
+
+	return routes
+}
+
+// registeredRoutes reads routes.go for every route given to a router, with
+// httprouter's :param and *param written the OpenAPI way
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paramRX := regexp.MustCompile(`[:*]([a-z_]+)`)
+
+	var routes []string
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || len(call.Args) < 2 {
+			return true
+		}
+
+		var method string
+
+		switch sel.Sel.Name {
+		case "HandlerFunc", "Handler":
+			constant, ok := call.Args[0].(*ast.SelectorExpr)
+			if !ok {
+				t.Fatalf("routes.go: %s method isn't an http.Method constant", sel.Sel.Name)
+			}
+			method = strings.ToUpper(strings.TrimPrefix(constant.Sel.Name, "Method"))
+		case "ServeFiles":
+			method = http.MethodGet
+		default:
+			return true
+		}
+
+		pathArg := call.Args[1]
+		if sel.Sel.Name == "ServeFiles" {
+			pathArg = call.Args[0]
+		}
+
+		lit, ok := pathArg.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			t.Fatalf("routes.go: %s path isn't a string literal", sel.Sel.Name)
+		}
+
+		path, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		routes = append(routes, method+" "+paramRX.ReplaceAllString(path, "{$1}"))
+
+		return true
+	})
+
+	sort.Strings(routes)
+
+	return routes
+}
+
+func TestOpenAPIDeclaresEveryRoute(t *testing.T) {
+	declared := make(map[string]bool)
+	for _, route := range specRoutes(t) {
+		declared[route] = true
+	}
+
+	for _, route := range registeredRoutes(t) {
+		if !declared[route] && !undocumented[route] {
+			t.Errorf("%s is routed but not in openapi.json", route)
+		}
+	}
+}
+
+func TestOpenAPIRoutesExist(t *testing.T) {
+	app := newTestApplication(t)
+	routes := app.routes()
+
+	for _, route := range specRoutes(t) {
+		method, path, _ := strings.Cut(route, " ")
+
+		target := specParamRX.ReplaceAllStringFunc(path, func(param string) string {
+			if value, ok := specParams[param[1:len(param)-1]]; ok {
+				return value
+			}
+			return "1"
+		})
+
+		t.Run(route, func(t *testing.T) {
+			// Streaming handlers run until the request is cancelled
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var body *strings.Reader
+			if method == http.MethodGet || method == http.MethodDelete {
+				body = strings.NewReader("")
+			} else {
+				body = strings.NewReader("{}")
+			}
+
+			r := httptest.NewRequest(method, target, body).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			routes.ServeHTTP(w, r)
+
+			var res struct {
+				Error any `json:"error"`
+			}
+
+			switch w.Code {
+			case http.StatusMethodNotAllowed:
+				t.Errorf("%s %s: method not allowed by the router", method, target)
+			case http.StatusNotFound:
+				json.Unmarshal(w.Body.Bytes(), &res)
+				t.Errorf("%s %s: not found: %v", method, target, res.Error)
+			}
+		})
+	}
+}
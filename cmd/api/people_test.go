@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestShowFilmography(t *testing.T) {
+	app := newTestApplication(t)
+	app.models = data.NewModels(testdb.New(t))
+
+	person := &data.Person{Name: "Ridley Scott"}
+
+	err := app.models.People.Insert(person)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movies := map[string]int64{}
+
+	for _, movie := range []*data.Movie{
+		{Title: "Alien", Year: 1979},
+		{Title: "Gladiator", Year: 2000},
+		{Title: "The Duellists", Year: 1977},
+		{Title: "Black Hawk Down", Year: 2001},
+		{Title: "Hannibal", Year: 2001},
+	} {
+		movie.Runtime = 120
+		movie.Genres = []string{"drama"}
+		movie.Status = "released"
+
+		err := app.models.Movies.Insert(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		movies[movie.Title] = movie.ID
+	}
+
+	// Inserted out of order so the response can't just follow the ids
+	credits := []*data.Credit{
+		{MovieID: movies["Alien"], Role: data.CreditRoleDirector},
+		{MovieID: movies["Hannibal"], Role: data.CreditRoleDirector},
+		{MovieID: movies["The Duellists"], Role: data.CreditRoleDirector},
+		{MovieID: movies["Black Hawk Down"], Role: data.CreditRoleCrew, Job: "Producer"},
+		{MovieID: movies["Gladiator"], Role: data.CreditRoleDirector},
+		{MovieID: movies["Black Hawk Down"], Role: data.CreditRoleDirector},
+	}
+
+	for _, credit := range credits {
+		credit.PersonID = person.ID
+
+		err := app.models.Credits.Insert(credit)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var body struct {
+		Person      data.Person `json:"person"`
+		Filmography []struct {
+			MovieTitle string `json:"movie_title"`
+			MovieYear  int32  `json:"movie_year"`
+			Role       string `json:"role"`
+		} `json:"filmography"`
+	}
+
+	code := getJSON(t, app, fmt.Sprintf("/v1/people/%d/filmography", person.ID), &body)
+	if code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", code, http.StatusOK)
+	}
+
+	if body.Person.ID != person.ID {
+		t.Errorf("got person %d; want %d", body.Person.ID, person.ID)
+	}
+
+	// Newest first, then in the order the movies and credits were added
+	want := []string{
+		"Black Hawk Down (2001) crew",
+		"Black Hawk Down (2001) director",
+		"Hannibal (2001) director",
+		"Gladiator (2000) director",
+		"Alien (1979) director",
+		"The Duellists (1977) director",
+	}
+
+	var got []string
+	for _, credit := range body.Filmography {
+		got = append(got, fmt.Sprintf("%s (%d) %s", credit.MovieTitle, credit.MovieYear, credit.Role))
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("got filmography\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestShowFilmographyNotFound(t *testing.T) {
+	app := newTestApplication(t)
+	app.models = data.NewModels(testdb.New(t))
+
+	var body struct {
+		Error any `json:"error"`
+	}
+
+	code := getJSON(t, app, "/v1/people/1000/filmography", &body)
+	if code != http.StatusNotFound {
+		t.Fatalf("got status %d; want %d", code, http.StatusNotFound)
+	}
+}
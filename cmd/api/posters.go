@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/poster"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+// Accepts either a multipart/form-data body with the image in a "poster"
+// field, or the raw image as the whole request body
+func (app *application) putMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	body, err := app.readPosterBody(w, r)
+	if err != nil {
+		app.badRequestHandler(w, r, err)
+		return
+	}
+
+	processed, err := poster.Process(body)
+	if err != nil {
+		var validationError poster.ValidationError
+
+		switch {
+		case errors.As(err, &validationError):
+			v := validator.New()
+			v.AddError("poster", validationError.Message)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Every upload gets a new random prefix so the URLs change and caches
+	// never serve the old image
+	suffix := make([]byte, 8)
+
+	_, err = rand.Read(suffix)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	key := fmt.Sprintf("posters/%d/%s", movie.ID, hex.EncodeToString(suffix))
+	urls := data.PosterURLs{}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	for size, image := range processed.Images {
+		err = app.blobs.Put(ctx, key+"/"+size, image.ContentType, image.Body)
+		if err != nil {
+			app.deletePosterBlobs(key, urls)
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		urls[size] = app.blobs.URL(key + "/" + size)
+	}
+
+	previousKey, previousURLs := movie.PosterKey, movie.Poster
+
+	movie.PosterKey = key
+	movie.Poster = urls
+
+	err = app.models.Movies.UpdatePoster(movie)
+	if err != nil {
+		app.deletePosterBlobs(key, urls)
+
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if previousKey != "" {
+		app.deletePosterBlobs(previousKey, previousURLs)
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movieResource{Movie: movie, Links: movieLinks(movie.ID)}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if movie.PosterKey == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	previousKey, previousURLs := movie.PosterKey, movie.Poster
+
+	movie.PosterKey = ""
+	movie.Poster = nil
+
+	err = app.models.Movies.UpdatePoster(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deletePosterBlobs(previousKey, previousURLs)
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "poster successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readPosterBody has its own size limit instead of the 1MB used by readRequest.
+// The limit applies to the whole request so a multipart body can't get round
+// it with lots of extra parts
+func (app *application) readPosterBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.blob.maxBytes)
+
+	var src io.Reader = r.Body
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			return nil, errors.New("body contains badly-formed multipart data")
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				var maxBytesError *http.MaxBytesError
+
+				switch {
+				case errors.As(err, &maxBytesError):
+					return nil, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+				case errors.Is(err, io.EOF):
+					return nil, errors.New(`body must contain a "poster" field`)
+				default:
+					return nil, errors.New("body contains badly-formed multipart data")
+				}
+			}
+
+			if part.FormName() == "poster" {
+				src = part
+				break
+			}
+		}
+	}
+
+	body, err := io.ReadAll(src)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			return nil, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return nil, err
+		}
+	}
+
+	if len(body) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+
+	return body, nil
+}
+
+// deleteMovie deletes a movie along with its poster, for the REST, GraphQL
+// and gRPC APIs alike
+func (app *application) deleteMovie(id int64) error {
+	posterKey, posterURLs, err := app.models.Movies.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	if posterKey != "" {
+		app.deletePosterBlobs(posterKey, posterURLs)
+	}
+
+	return nil
+}
+
+// deletePosterBlobs cleans up in the background, a failure only leaves an
+// orphaned file behind so it is logged rather than returned
+func (app *application) deletePosterBlobs(key string, urls data.PosterURLs) {
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		for size := range urls {
+			err := app.blobs.Delete(ctx, key+"/"+size)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"blob": key + "/" + size})
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/storage"
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestDeleteMovieRemovesPoster(t *testing.T) {
+	app := newTestApplication(t)
+	app.models = data.NewModels(testdb.New(t))
+
+	dir := t.TempDir()
+	app.blobs = &storage.FileSystemStore{Dir: dir, BaseURL: "/v1/blobs"}
+
+	movie := &data.Movie{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"horror"}, Status: "released"}
+
+	err := app.models.Movies.Insert(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "posters/1/abc"
+	movie.PosterKey = key
+	movie.Poster = data.PosterURLs{}
+
+	for _, size := range []string{"original", "w92"} {
+		err := app.blobs.Put(context.Background(), key+"/"+size, "image/jpeg", []byte("image"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		movie.Poster[size] = app.blobs.URL(key + "/" + size)
+	}
+
+	err = app.models.Movies.UpdatePoster(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.deleteMovie(movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The blobs are removed in the background
+	app.wg.Wait()
+
+	for _, size := range []string{"original", "w92"} {
+		_, err := os.Stat(filepath.Join(dir, "posters", "1", "abc", size))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: got %v, want it deleted", size, err)
+		}
+	}
+
+	err = app.deleteMovie(movie.ID)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("deleting it again: got %v, want ErrRecordNotFound", err)
+	}
+}
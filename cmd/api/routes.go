@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/jim-at-jibba/greenlight/internal/storage"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -14,11 +15,102 @@ func (app *application) routes() http.Handler {
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openapiHandler)
+
+	if app.config.env == "development" {
+		router.HandlerFunc(http.MethodGet, "/v1/docs", app.docsHandler)
+	}
+
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMovieHander)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.listMovieCreditsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermission("movies:write", app.createMovieCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requirePermission("movies:write", app.deleteMovieCreditHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/poster", app.requirePermission("movies:write", app.putMoviePosterHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/poster", app.requirePermission("movies:write", app.deleteMoviePosterHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/external-ids/:provider", app.requirePermission("movies:write", app.putMovieExternalIDHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/external-ids/:provider", app.requirePermission("movies:write", app.deleteMovieExternalIDHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.listSimilarMoviesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/translations", app.listMovieTranslationsHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/translations/:language", app.requirePermission("movies:write", app.putMovieTranslationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/translations/:language", app.requirePermission("movies:write", app.deleteMovieTranslationHandler))
+
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/rating", app.requireAuthenticatedUser(app.putMovieRatingHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/rating", app.requireAuthenticatedUser(app.deleteMovieRatingHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.listMovieReviewsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requireAuthenticatedUser(app.createMovieReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/reviews/:review_id", app.requireAuthenticatedUser(app.updateMovieReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/reviews/:review_id", app.requireAuthenticatedUser(app.deleteMovieReviewHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.listPeopleHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("movies:write", app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.showPersonHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("movies:write", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("movies:write", app.deletePersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id/filmography", app.showFilmographyHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.listGenresHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("genres:write", app.createGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission("genres:write", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:id/merge", app.requirePermission("genres:write", app.mergeGenreHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/collections", app.listCollectionsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermission("collections:write", app.createCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.showCollectionHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermission("collections:write", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermission("collections:write", app.deleteCollectionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections/:id/movies", app.requirePermission("collections:write", app.createCollectionMovieHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id/movies/:movie_id", app.requirePermission("collections:write", app.updateCollectionMovieHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id/movies/:movie_id", app.requirePermission("collections:write", app.deleteCollectionMovieHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/lists", app.requireAuthenticatedUser(app.listListsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists", app.requireAuthenticatedUser(app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.requireAuthenticatedUser(app.showListHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id", app.requireAuthenticatedUser(app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id", app.requireAuthenticatedUser(app.deleteListHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists/:id/items", app.requireAuthenticatedUser(app.createListItemHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id/items/:movie_id", app.requireAuthenticatedUser(app.updateListItemHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id/items/:movie_id", app.requireAuthenticatedUser(app.deleteListItemHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/shared/lists/:slug", app.showSharedListHandler)
+
+	// The fs blob store is served by the API itself unless it has been
+	// pointed at another host
+	if app.config.blob.store == "fs" && app.config.blob.baseURL == "/v1/blobs" {
+		blobs := &storage.FileSystemStore{Dir: app.config.blob.dir}
+		router.Handler(http.MethodGet, "/v1/blobs/*filepath", http.StripPrefix("/v1/blobs", blobs.FileServer()))
+	}
+
+	schema, err := app.graphqlSchema()
+	if err != nil {
+		panic(err)
+	}
+
+	router.HandlerFunc(http.MethodPost, "/v1/graphql", app.graphqlHandler(schema))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+
+	// httprouter won't let a static segment sit in the same place as the :id
+	// wildcard, so routes like /v1/movies/by-external/... go on a second
+	// router which is tried before the main one
+	static := httprouter.New()
+
+	static.HandlerFunc(http.MethodGet, "/v1/movies/by-external/:provider/:external_id", app.showMovieByExternalIDHandler)
+	static.HandlerFunc(http.MethodGet, "/v1/movies/events", app.movieEventsHandler)
+
+	return app.compress(app.recoverPanic(app.rateLimit(app.authenticate(app.withStaticRoutes(static, router)))))
+}
+
+func (app *application) withStaticRoutes(static, router *httprouter.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle, params, _ := static.Lookup(r.Method, r.URL.Path); handle != nil {
+			handle(w, r, params)
+			return
+		}
 
-	return app.recoverPanic(app.rateLimit(router))
+		router.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+var (
+	runtimeType       = reflect.TypeOf(data.Runtime(0))
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// withRuntimeFormat returns v ready to be encoded with every data.Runtime in
+// it written in format. data.Runtime can't know which format a request asked
+// for, so maps, slices and structs are copied with their runtimes swapped
+// for strings. Structs become jsonObjects with the same fields encoding/json
+// would write, in the same order. Types with their own MarshalJSON or
+// MarshalText are left as they are
+func withRuntimeFormat(v any, format data.RuntimeFormat) any {
+	if format == data.RuntimeMins {
+		return v
+	}
+
+	return formatRuntimes(reflect.ValueOf(v), format)
+}
+
+func formatRuntimes(v reflect.Value, format data.RuntimeFormat) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	// A pointer has its element's methods too, so look through it first.
+	// The element is addressable, which keeps methods with pointer
+	// receivers in play below
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+
+		return formatRuntimes(v.Elem(), format)
+	}
+
+	if v.Type() == runtimeType {
+		return data.Runtime(v.Int()).Format(format)
+	}
+
+	if v.CanAddr() && (reflect.PointerTo(v.Type()).Implements(marshalerType) || reflect.PointerTo(v.Type()).Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+
+	if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		m := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), reflect.TypeOf((*any)(nil)).Elem()), v.Len())
+
+		iter := v.MapRange()
+		for iter.Next() {
+			value := formatRuntimes(iter.Value(), format)
+			if value == nil {
+				m.SetMapIndex(iter.Key(), reflect.Zero(m.Type().Elem()))
+				continue
+			}
+			m.SetMapIndex(iter.Key(), reflect.ValueOf(value))
+		}
+
+		return m.Interface()
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		// Encoded as base64, there's no runtime in there
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+
+		s := make([]any, v.Len())
+		for i := range s {
+			s[i] = formatRuntimes(v.Index(i), format)
+		}
+
+		return s
+
+	case reflect.Struct:
+		fields := jsonFields(v)
+
+		obj := make(jsonObject, len(fields))
+		for i, field := range fields {
+			obj[i] = jsonField{name: field.name, value: formatRuntimes(field.value, format)}
+		}
+
+		return obj
+
+	default:
+		return v.Interface()
+	}
+}
+
+// jsonObject is a struct that has been through withRuntimeFormat, its fields
+// are written in order like the struct's would have been
+type jsonObject []jsonField
+
+type jsonField struct {
+	name  string
+	value any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(field.name)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// structField is one field encoding/json writes for a struct value
+type structField struct {
+	name  string
+	value reflect.Value
+}
+
+// jsonFields returns the fields encoding/json would write for the struct v,
+// in order, following its rules for tags, omitempty and embedded structs.
+// Fields left out by omitempty, or reached through a nil embedded pointer,
+// aren't returned. The string tag option isn't used anywhere and isn't
+// supported
+func jsonFields(v reflect.Value) []structField {
+	var candidates []fieldCandidate
+	collectFields(v.Type(), nil, map[reflect.Type]bool{}, &candidates)
+
+	// Where two fields have the same name the shallowest wins, then one
+	// with a tag. If that still leaves more than one, neither is written
+	byName := make(map[string][]fieldCandidate, len(candidates))
+	for _, c := range candidates {
+		byName[c.name] = append(byName[c.name], c)
+	}
+
+	var fields []structField
+
+	for _, c := range candidates {
+		if !dominant(c, byName[c.name]) {
+			continue
+		}
+
+		value, ok := fieldByIndex(v, c.index)
+		if !ok || (c.omitEmpty && isEmptyValue(value)) {
+			continue
+		}
+
+		fields = append(fields, structField{name: c.name, value: value})
+	}
+
+	return fields
+}
+
+type fieldCandidate struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+}
+
+// collectFields walks t depth first, which is the order encoding/json
+// writes fields in
+func collectFields(t reflect.Type, index []int, visiting map[reflect.Type]bool, candidates *[]fieldCandidate) {
+	if visiting[t] {
+		return
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if sf.Anonymous {
+			if !sf.IsExported() && ft.Kind() != reflect.Struct {
+				continue
+			}
+
+			if name == "" && ft.Kind() == reflect.Struct {
+				collectFields(ft, append(append([]int(nil), index...), i), visiting, candidates)
+				continue
+			}
+		} else if !sf.IsExported() {
+			continue
+		}
+
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+
+		*candidates = append(*candidates, fieldCandidate{
+			name:      name,
+			index:     append(append([]int(nil), index...), i),
+			tagged:    tagged,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+}
+
+func dominant(c fieldCandidate, all []fieldCandidate) bool {
+	for _, other := range all {
+		if len(other.index) < len(c.index) {
+			return false
+		}
+	}
+
+	rivals := 0
+
+	for _, other := range all {
+		if len(other.index) != len(c.index) {
+			continue
+		}
+
+		if other.tagged && !c.tagged {
+			return false
+		}
+
+		if other.tagged == c.tagged {
+			rivals++
+		}
+	}
+
+	return rivals == 1
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, except that it reports false
+// rather than panicking at a nil embedded pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// isEmptyValue is what counts as empty for omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+
+	return false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+func TestWithRuntimeFormat(t *testing.T) {
+	runtime := data.Runtime(102)
+
+	v := envelope{
+		"movie": movieResource{
+			Movie: &data.Movie{ID: 1, Title: "90 mins", Runtime: 102, Genres: []string{"drama"}, Status: "released"},
+			Links: movieLinks(1),
+		},
+		"collection": &data.Collection{ID: 2, Name: "Trilogy", TotalRuntime: 330},
+		// Runtimes are found by type, whatever they're called
+		"anything": map[string]any{"length": data.Runtime(45), "nested": []any{&runtime, nil}},
+		"note":     "102 mins",
+	}
+
+	tests := []struct {
+		format data.RuntimeFormat
+		want   string
+	}{
+		{
+			format: data.RuntimeMins,
+			want:   `{"anything":{"length":"45 mins","nested":["102 mins",null]},"collection":{"id":2,"name":"Trilogy","version":0,"total_runtime":"330 mins"},"movie":{"id":1,"title":"90 mins","runtime":"102 mins","genres":["drama"],"version":0,"average_rating":0,"rating_count":0,"status":"released","links":{"collection":"/v1/movies","self":"/v1/movies/1"}},"note":"102 mins"}`,
+		},
+		{
+			format: data.RuntimeHM,
+			want:   `{"anything":{"length":"45m","nested":["1h 42m",null]},"collection":{"id":2,"name":"Trilogy","version":0,"total_runtime":"5h 30m"},"movie":{"id":1,"title":"90 mins","runtime":"1h 42m","genres":["drama"],"version":0,"average_rating":0,"rating_count":0,"status":"released","links":{"collection":"/v1/movies","self":"/v1/movies/1"}},"note":"102 mins"}`,
+		},
+		{
+			format: data.RuntimeISO8601,
+			want:   `{"anything":{"length":"PT45M","nested":["PT1H42M",null]},"collection":{"id":2,"name":"Trilogy","version":0,"total_runtime":"PT5H30M"},"movie":{"id":1,"title":"90 mins","runtime":"PT1H42M","genres":["drama"],"version":0,"average_rating":0,"rating_count":0,"status":"released","links":{"collection":"/v1/movies","self":"/v1/movies/1"}},"note":"102 mins"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			js, err := json.Marshal(withRuntimeFormat(v, tt.format))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(js) != tt.want {
+				t.Errorf("got  %s\nwant %s", js, tt.want)
+			}
+		})
+	}
+}
+
+// Without any runtimes in it, a value must encode exactly as it did before
+func TestWithRuntimeFormatMatchesEncodingJSON(t *testing.T) {
+	type Inner struct {
+		A string `json:"a"`
+		B int    `json:"b,omitempty"`
+		C string
+	}
+
+	type Other struct {
+		A string `json:"a"`
+		D string `json:"d"`
+	}
+
+	type tagged struct {
+		E string `json:"e"`
+	}
+
+	type Outer struct {
+		Inner
+		*Other
+		tagged
+		Named    Inner  `json:"named"`
+		A        string `json:"a,omitempty"`
+		Skipped  string `json:"-"`
+		Dash     string `json:"-,"`
+		private  string
+		Empty    []string       `json:"empty,omitempty"`
+		Nil      []string       `json:"nil"`
+		Bytes    []byte         `json:"bytes"`
+		Time     time.Time      `json:"time"`
+		Ptr      *Inner         `json:"ptr,omitempty"`
+		Map      map[int]string `json:"map"`
+		Any      any            `json:"any"`
+		Array    [2]bool        `json:"array"`
+		Float    float64        `json:"float,omitempty"`
+		Raw      json.RawMessage
+		Untagged int
+	}
+
+	values := []any{
+		Outer{
+			Inner:  Inner{A: "inner", B: 1, C: "c"},
+			Other:  &Other{A: "other", D: "d"},
+			tagged: tagged{E: "e"},
+			Named:  Inner{A: "named"},
+			Bytes:  []byte("<bytes>"),
+			Time:   time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC),
+			Map:    map[int]string{2: "two", 1: "<one>"},
+			Any:    map[string]any{"x": []any{1.5, "y", nil}},
+			Raw:    json.RawMessage(`{"raw":true}`),
+		},
+		Outer{A: "outer", Ptr: &Inner{}},
+		&Outer{},
+		[]*Outer{nil},
+	}
+
+	for _, v := range values {
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := json.Marshal(withRuntimeFormat(v, data.RuntimeHM))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != string(want) {
+			t.Errorf("got  %s\nwant %s", got, want)
+		}
+	}
+}
+
+func TestSparseMoviesRuntimeFormat(t *testing.T) {
+	app := newTestApplication(t)
+
+	movies := []*data.Movie{{ID: 1, Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}}}
+
+	sparse, err := app.sparseMovies(movies, []string{"title", "runtime"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies?fields=title,runtime&runtime_format=iso8601&pretty=false", nil)
+	w := httptest.NewRecorder()
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movies": sparse}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"movies":[{"id":1,"links":{"collection":"/v1/movies","self":"/v1/movies/1"},"runtime":"PT1H47M","title":"Moana"}]}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("got  %s\nwant %s", w.Body, want)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 func (app *application) serve() error {
@@ -22,6 +25,37 @@ func (app *application) serve() error {
 
 	shutdownError := make(chan error)
 
+	// Background jobs get their own context which is cancelled as soon as
+	// shutdown starts
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startJobs(jobsCtx)
+
+	// The gRPC server shares the shutdown below, its Watch streams end as
+	// soon as jobsCtx is cancelled
+	var grpcSrv *grpc.Server
+
+	if app.config.grpc.port != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", app.config.grpc.port))
+		if err != nil {
+			return err
+		}
+
+		grpcSrv = app.grpcServer(jobsCtx)
+
+		go func() {
+			app.logger.PrintInfo("starting grpc server", map[string]string{
+				"addr": listener.Addr().String(),
+			})
+
+			err := grpcSrv.Serve(listener)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		}()
+	}
+
 	go func() {
 
 		// Create quit channel which carries os.Signal values
@@ -42,11 +76,10 @@ func (app *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
-		// Call Shutdown() on out server, passing in the context we just made
-		// Shutdown() will return nil if the graceful hsutdown was a succcess or an error
-		// due to issues closing hte listneers or not within 20 seconds graceful
-		// This is relayed with the shutdownError
-		shutdownError <- srv.Shutdown(ctx)
+		stopJobs()
+
+		// Relay the result of the shutdown back to serve()
+		shutdownError <- app.shutdown(ctx, srv, grpcSrv)
 	}()
 
 	app.logger.PrintInfo("starting server", map[string]string{
@@ -77,3 +110,46 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+// shutdown stops both servers and waits for anything started with
+// app.background() to finish. Every step runs even if an earlier one fails,
+// a server that didn't stop in time mustn't leave the rest running, and the
+// first error is returned
+func (app *application) shutdown(ctx context.Context, srv *http.Server, grpcSrv *grpc.Server) error {
+	// Shutdown() will return nil if the graceful shutdown was a success or an
+	// error due to issues closing the listeners or not finishing within ctx
+	err := srv.Shutdown(ctx)
+
+	if grpcSrv != nil {
+		if grpcErr := stopGRPC(ctx, grpcSrv); err == nil {
+			err = grpcErr
+		}
+	}
+
+	app.logger.PrintInfo("completing background tasks", map[string]string{
+		"addr": srv.Addr,
+	})
+
+	app.wg.Wait()
+
+	return err
+}
+
+// stopGRPC waits for in-flight calls to finish, cutting them off if ctx
+// runs out first
+func stopGRPC(ctx context.Context, srv *grpc.Server) error {
+	stopped := make(chan struct{})
+
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// A request that outlasts the shutdown timeout mustn't stop the gRPC server
+// being stopped or background tasks being waited for
+func TestShutdownRunsEveryStep(t *testing.T) {
+	app := newTestApplication(t)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go srv.Serve(httpListener)
+
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grpcSrv := grpc.NewServer()
+	grpcStopped := make(chan struct{})
+
+	go func() {
+		grpcSrv.Serve(grpcListener)
+		close(grpcStopped)
+	}()
+
+	var finished atomic.Bool
+
+	app.background(func() {
+		time.Sleep(200 * time.Millisecond)
+		finished.Store(true)
+	})
+
+	go http.Get("http://" + httpListener.Addr().String())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = app.shutdown(ctx, srv, grpcSrv)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the HTTP server's context.DeadlineExceeded", err)
+	}
+
+	select {
+	case <-grpcStopped:
+	default:
+		t.Error("gRPC server still running")
+	}
+
+	if !finished.Load() {
+		t.Error("returned before background tasks finished")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+// Similar movies come from the precomputed table when the refresh job is
+// running, falling back to scoring on request for movies it hasn't seen yet
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+
+	v.CheckError(limit > 0, "limit", validator.NewError(validator.CodeMin, "must be greater than zero", validator.Params{"min": 1}))
+	v.CheckError(limit <= 50, "limit", validator.NewError(validator.CodeMax, "must be a maximum of 50", validator.Params{"max": 50}))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var similar []*data.SimilarMovie
+
+	precomputed := false
+
+	if app.config.similar.interval > 0 {
+		similar, err = app.models.Similarities.GetPrecomputed(movie.ID, limit)
+		switch {
+		case err == nil:
+			precomputed = true
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !precomputed {
+		similar, err = app.models.Similarities.GetSimilar(movie.ID, limit, app.config.similar.weights)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"similar": similar}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
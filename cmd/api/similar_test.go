@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestListSimilarMoviesFallsBack(t *testing.T) {
+	app := newTestApplication(t)
+	app.models = data.NewModels(testdb.New(t))
+	app.config.similar.interval = time.Hour
+	app.config.similar.weights = data.SimilarityWeights{Year: 1}
+
+	ids := map[string]int64{}
+
+	insert := func(title string, year int32, genres ...string) {
+		movie := &data.Movie{Title: title, Year: year, Runtime: 100, Genres: genres, Status: data.MovieStatusReleased}
+
+		err := app.models.Movies.Insert(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids[title] = movie.ID
+	}
+
+	insert("Alien", 1979, "horror", "science-fiction")
+	insert("Aliens", 1986, "action", "horror", "science-fiction")
+	insert("Up", 2009, "animation")
+
+	// The refresh scores on genres alone, the fallback on year alone, so
+	// the response shows which one answered
+	_, err := app.models.Similarities.Refresh(context.Background(), data.SimilarityWeights{Genres: 1}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insert("Prometheus", 2012, "science-fiction")
+
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{"Alien", []string{"Aliens"}},
+		// Scored by the refresh, with nothing similar
+		{"Up", []string{}},
+		// Added since the refresh
+		{"Prometheus", []string{"Up"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var body struct {
+				Similar []struct {
+					Movie struct {
+						Title string `json:"title"`
+					} `json:"movie"`
+				} `json:"similar"`
+			}
+
+			status := getJSON(t, app, fmt.Sprintf("/v1/movies/%d/similar", ids[tt.title]), &body)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d; want %d", status, http.StatusOK)
+			}
+
+			got := []string{}
+			for _, similar := range body.Similar {
+				got = append(got, similar.Movie.Title)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
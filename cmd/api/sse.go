@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+const (
+	// sseHeartbeat keeps idle connections from being closed by proxies
+	sseHeartbeat = 15 * time.Second
+	// sseWriteTimeout is how long a client gets to take each event. One
+	// that can't keep up is disconnected and can resume with Last-Event-ID
+	sseWriteTimeout = 10 * time.Second
+	// sseRetry is how long browsers wait before reconnecting, in ms
+	sseRetry = 3000
+)
+
+// movieEventsHandler streams movie changes as server-sent events. Each
+// event's id is its position in the feed, a client that reconnects with
+// Last-Event-ID (or ?last_event_id, as EventSource can't set headers the
+// first time) is sent everything it missed first. If the missed events have
+// already been pruned it gets a reset event and should reload what it has
+func (app *application) movieEventsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var last int64
+
+	if lastEventID != "" {
+		var err error
+
+		last, err = strconv.ParseInt(lastEventID, 10, 64)
+		v.CheckError(err == nil && last >= 0, "last_event_id", validator.NewError(validator.CodeMin, "must be a positive integer", validator.Params{"min": 0}))
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Subscribe before reading what was missed so nothing committed in
+	// between falls through the gap
+	events, unsubscribe := app.events.subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stop nginx buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(format string, args ...any) error {
+		err := rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, format, args...)
+		if err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	sendEvent := func(event movieEvent) error {
+		js, err := app.marshalMovieEvent(r, event)
+		if err != nil {
+			return err
+		}
+
+		return send("id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, js)
+	}
+
+	err := send("retry: %d\n\n", sseRetry)
+	if err != nil {
+		return
+	}
+
+	if lastEventID != "" {
+		last, err = app.replayMovieEvents(r, last, sendEvent, send)
+		if err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		// The server is shutting down, the client will reconnect to
+		// another instance or once this one is back
+		case <-app.events.closing():
+			return
+
+		case event, ok := <-events:
+			// Too far behind, the client resumes from the last id it got
+			if !ok {
+				return
+			}
+
+			// Already sent while replaying
+			if event.Seq <= last {
+				continue
+			}
+
+			err := sendEvent(event)
+			if err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			err := send(": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// replayMovieEvents sends the stored events after last and returns the id of
+// the newest one it sent. Database errors are logged here, any error means
+// the stream should end
+func (app *application) replayMovieEvents(r *http.Request, last int64, sendEvent func(movieEvent) error, send func(string, ...any) error) (int64, error) {
+	oldest, _, err := app.models.MovieEvents.Bounds()
+	if err != nil {
+		app.logError(r, err)
+		return last, err
+	}
+
+	if oldest > last+1 {
+		err := send("event: reset\ndata: {}\n\n")
+		if err != nil {
+			return last, err
+		}
+	}
+
+	for {
+		events, err := app.models.MovieEvents.GetAfter(last, movieEventBatch)
+		if err != nil {
+			app.logError(r, err)
+			return last, err
+		}
+
+		for _, event := range events {
+			movie, err := app.loadMovieEvent(event)
+			if err != nil {
+				app.logError(r, err)
+				return last, err
+			}
+
+			err = sendEvent(movieEvent{Seq: event.ID, Type: event.Type, ID: event.MovieID, Movie: movie})
+			if err != nil {
+				return last, err
+			}
+
+			last = event.ID
+		}
+
+		if len(events) < movieEventBatch {
+			return last, nil
+		}
+	}
+}
+
+// marshalMovieEvent writes an event's data on one line, pretty printing
+// would split it over several
+func (app *application) marshalMovieEvent(r *http.Request, event movieEvent) ([]byte, error) {
+	env := envelope{"type": event.Type, "id": event.ID}
+
+	if event.Movie != nil {
+		env["movie"] = movieResource{Movie: event.Movie, Links: movieLinks(event.ID)}
+	}
+
+	return json.Marshal(withRuntimeFormat(env, app.readRuntimeFormat(r)))
+}
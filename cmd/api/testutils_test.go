@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/i18n"
+	"github.com/jim-at-jibba/greenlight/internal/jsonlog"
+)
+
+// newTestApplication returns an application with no database behind its
+// models, anything that reaches one panics and is answered with a 500 by
+// recoverPanic. Tests that need data pass their own models
+func newTestApplication(t testing.TB) *application {
+	t.Helper()
+
+	bundle, err := i18n.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config
+	cfg.env = "development"
+	cfg.graphql.maxDepth = 8
+	cfg.graphql.maxComplexity = 10000
+
+	return &application{
+		config: cfg,
+		logger: jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		models: data.NewModels(nil),
+		i18n:   bundle,
+		events: newMovieBroker(),
+	}
+}
+
+// newTestUser inserts an activated user holding permissions into db and
+// returns an authentication token for them
+func newTestUser(t *testing.T, app *application, db *sql.DB, permissions ...string) string {
+	t.Helper()
+
+	user := &data.User{Name: "Alice", Email: "alice@example.com", Activated: true}
+
+	err := user.Password.Set("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, code := range permissions {
+		_, err := db.Exec(`INSERT INTO users_permissions SELECT $1, id FROM permissions WHERE code = $2`, user.ID, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token, err := app.models.Tokens.New(user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token.Plaintext
+}
+
+// getJSON sends a GET request for path through the application's routes and
+// decodes the response body into dst, returning the status code
+func getJSON(t *testing.T, app *application, path string, dst any) int {
+	t.Helper()
+
+	return sendJSON(t, app, http.MethodGet, path, nil, dst)
+}
+
+// sendJSON is getJSON for any method, body is encoded as the request's JSON
+// body unless it's nil
+func sendJSON(t *testing.T, app *application, method, path string, body, dst any) int {
+	t.Helper()
+
+	var reqBody io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	err := json.NewDecoder(rr.Body).Decode(dst)
+	if err != nil {
+		t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+
+	return rr.Code
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegisterUserInvalidPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		code     string
+	}{
+		{"missing", "", "required"},
+		{"too short", "pa55", "min_length"},
+		// bcrypt can't hash these, that mustn't turn into a 500
+		{"too long", strings.Repeat("a", 73), "max_length"},
+		{"too long multibyte", strings.Repeat("é", 37), "max_length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			var body struct {
+				Fields map[string][]struct {
+					Code string `json:"code"`
+				} `json:"fields"`
+			}
+
+			input := map[string]string{"name": "", "email": "alice@example.com", "password": tt.password}
+
+			status := sendJSON(t, app, http.MethodPost, "/v1/users", input, &body)
+			if status != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d", status, http.StatusUnprocessableEntity)
+			}
+
+			if got := body.Fields["password"]; len(got) == 0 || got[0].Code != tt.code {
+				t.Errorf("got password errors %v; want %q first", got, tt.code)
+			}
+
+			// The rest of the user is still checked
+			if _, ok := body.Fields["name"]; !ok {
+				t.Errorf("got no error for name")
+			}
+		})
+	}
+}
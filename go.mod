@@ -1,10 +1,27 @@
 module github.com/jim-at-jibba/greenlight
 
-go 1.19
+go 1.22
 
-require github.com/julienschmidt/httprouter v1.3.0
+require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/graphql-go/graphql v0.8.1
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/klauspost/compress v1.18.0
+	github.com/lib/pq v1.10.2
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/crypto v0.27.0
+	golang.org/x/image v0.7.0
+	golang.org/x/text v0.18.0
+	golang.org/x/time v0.3.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1
+	google.golang.org/grpc v1.68.1
+	google.golang.org/protobuf v1.34.2
+)
 
 require (
-	github.com/lib/pq v1.10.2 // indirect
-	golang.org/x/time v0.3.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/net v0.29.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
 )
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrDuplicateCollectionMovie = errors.New("duplicate collection movie")
+
+// CollectionMovie is a movie's membership of a collection. Position starts at
+// 1 and is kept contiguous, the same way as list items
+type CollectionMovie struct {
+	CollectionID int64 `json:"collection_id"`
+	MovieID      int64 `json:"movie_id"`
+	Position     int32 `json:"position"`
+}
+
+type CollectionMovieModel struct {
+	DB *sql.DB
+}
+
+// Insert adds the movie to the end of the collection. The collection row is
+// locked so two movies added at the same time don't get the same position
+func (m CollectionMovieModel) Insert(member *CollectionMovie) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockCollection(ctx, tx, member.CollectionID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+  INSERT INTO collection_movies (collection_id, movie_id, position)
+  SELECT $1, $2, COALESCE(MAX(position), 0) + 1
+  FROM collection_movies
+  WHERE collection_id = $1
+  RETURNING position
+  `
+
+	err = tx.QueryRowContext(ctx, query, member.CollectionID, member.MovieID).Scan(&member.Position)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "collection_movies_pkey"`:
+			return ErrDuplicateCollectionMovie
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Move works like ListItemModel.Move, positions past the end are clamped to
+// the last position and the movies in between shift by one
+func (m CollectionMovieModel) Move(member *CollectionMovie, position int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockCollection(ctx, tx, member.CollectionID)
+	if err != nil {
+		return err
+	}
+
+	var current, last int32
+
+	err = tx.QueryRowContext(ctx, `
+  SELECT cm.position, (SELECT MAX(position) FROM collection_movies WHERE collection_id = $1)
+  FROM collection_movies cm
+  WHERE cm.collection_id = $1 AND cm.movie_id = $2`, member.CollectionID, member.MovieID).Scan(&current, &last)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if position > last {
+		position = last
+	}
+
+	switch {
+	case position < current:
+		_, err = tx.ExecContext(ctx, `
+  UPDATE collection_movies
+  SET position = position + 1
+  WHERE collection_id = $1 AND position >= $2 AND position < $3`, member.CollectionID, position, current)
+	case position > current:
+		_, err = tx.ExecContext(ctx, `
+  UPDATE collection_movies
+  SET position = position - 1
+  WHERE collection_id = $1 AND position > $2 AND position <= $3`, member.CollectionID, current, position)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+  UPDATE collection_movies
+  SET position = $1
+  WHERE collection_id = $2 AND movie_id = $3`, position, member.CollectionID, member.MovieID)
+
+	if err != nil {
+		return err
+	}
+
+	member.Position = position
+
+	return tx.Commit()
+}
+
+// Movies after the removed one move up a place so positions stay contiguous
+func (m CollectionMovieModel) Delete(collectionID, movieID int64) error {
+	if collectionID < 1 || movieID < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockCollection(ctx, tx, collectionID)
+	if err != nil {
+		return err
+	}
+
+	var position int32
+
+	err = tx.QueryRowContext(ctx, `
+  DELETE FROM collection_movies
+  WHERE collection_id = $1 AND movie_id = $2
+  RETURNING position`, collectionID, movieID).Scan(&position)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+  UPDATE collection_movies
+  SET position = position - 1
+  WHERE collection_id = $1 AND position > $2`, collectionID, position)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetMovies returns every movie in the collection in position order.
+// Collections are small so there is no paging
+func (m CollectionMovieModel) GetMovies(collectionID int64) ([]*Movie, error) {
+	query := `
+  SELECT ` + movieColumns + `
+  FROM collection_movies cm
+  INNER JOIN movies ON movies.id = cm.movie_id
+  WHERE cm.collection_id = $1
+  ORDER BY cm.position ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movieDest(&movie)...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+func lockCollection(ctx context.Context, tx *sql.Tx, collectionID int64) error {
+	var id int64
+
+	err := tx.QueryRowContext(ctx, `SELECT id FROM collections WHERE id = $1 FOR UPDATE`, collectionID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
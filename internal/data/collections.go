@@ -0,0 +1,217 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+var ErrDuplicateCollection = errors.New("duplicate collection")
+
+// Collection groups movies that belong together, like a franchise or a
+// trilogy. Movies and TotalRuntime are only filled in when the collection is
+// read with its members, see SetMovies
+type Collection struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"-"`
+	Name         string    `json:"name"`
+	Overview     string    `json:"overview,omitempty"`
+	Version      int32     `json:"version"`
+	TotalRuntime Runtime   `json:"total_runtime,omitempty"`
+	Movies       []*Movie  `json:"movies,omitempty"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.CheckError(collection.Name != "", "name", validator.Required())
+	v.CheckError(len(collection.Name) <= 500, "name", validator.MaxLength(500))
+	v.CheckError(len(collection.Overview) <= 10_000, "overview", validator.MaxLength(10_000))
+}
+
+// SetMovies stores the members in order and adds up their runtimes. Movies
+// with no runtime yet count as zero
+func (c *Collection) SetMovies(movies []*Movie) {
+	c.Movies = movies
+	c.TotalRuntime = 0
+
+	for _, movie := range movies {
+		c.TotalRuntime += movie.Runtime
+	}
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	query := `
+  INSERT INTO collections (name, overview)
+  VALUES ($1, $2)
+  RETURNING id, created_at, version
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, collection.Name, collection.Overview).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "collections_name_key"`:
+			return ErrDuplicateCollection
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+  SELECT id, created_at, name, overview, version
+  FROM collections
+  WHERE id = $1
+  `
+
+	var collection Collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Name,
+		&collection.Overview,
+		&collection.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &collection, nil
+}
+
+func (m CollectionModel) Update(collection *Collection) error {
+	query := `
+  UPDATE collections
+  SET name = $1, overview = $2, version = version + 1
+  WHERE id = $3 AND version = $4
+  RETURNING version
+  `
+
+	args := []any{
+		collection.Name,
+		collection.Overview,
+		collection.ID,
+		collection.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "collections_name_key"`:
+			return ErrDuplicateCollection
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+  DELETE FROM collections
+  WHERE id = $1
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m CollectionModel) GetAll(name string, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(), id, created_at, name, overview, version
+  FROM collections
+  WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+  ORDER BY %s %s, id ASC
+  LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.Name,
+			&collection.Overview,
+			&collection.Version,
+		)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return collections, metadata, nil
+}
@@ -0,0 +1,185 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestCollectionSetMovies(t *testing.T) {
+	tests := []struct {
+		name     string
+		runtimes []Runtime
+		want     Runtime
+	}{
+		{"empty", nil, 0},
+		{"one", []Runtime{117}, 117},
+		{"several", []Runtime{117, 137, 114}, 368},
+		// Announced movies may not have a runtime yet
+		{"missing runtime", []Runtime{117, 0, 114}, 231},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var movies []*Movie
+			for _, runtime := range tt.runtimes {
+				movies = append(movies, &Movie{Runtime: runtime})
+			}
+
+			collection := &Collection{TotalRuntime: 999}
+			collection.SetMovies(movies)
+
+			if collection.TotalRuntime != tt.want {
+				t.Errorf("got total runtime %d; want %d", collection.TotalRuntime, tt.want)
+			}
+
+			if len(collection.Movies) != len(movies) {
+				t.Errorf("got %d movies; want %d", len(collection.Movies), len(movies))
+			}
+		})
+	}
+}
+
+func TestCollectionMovieOrdering(t *testing.T) {
+	models := NewModels(testdb.New(t))
+
+	collection := &Collection{Name: "Alien"}
+
+	err := models.Collections.Insert(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]int64{}
+
+	for _, movie := range []*Movie{
+		{Title: "Alien", Year: 1979, Runtime: 117},
+		{Title: "Aliens", Year: 1986, Runtime: 137},
+		{Title: "Alien 3", Year: 1992, Runtime: 114},
+		{Title: "Alien Resurrection", Year: 1997, Runtime: 109},
+	} {
+		movie.Genres = []string{"horror"}
+		movie.Status = MovieStatusReleased
+
+		err := models.Movies.Insert(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids[movie.Title] = movie.ID
+
+		err = models.CollectionMovies.Insert(&CollectionMovie{CollectionID: collection.ID, MovieID: movie.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name     string
+		run      func() error
+		want     []string
+		wantTime Runtime
+	}{
+		{
+			name:     "added in order",
+			run:      func() error { return nil },
+			want:     []string{"Alien", "Aliens", "Alien 3", "Alien Resurrection"},
+			wantTime: 477,
+		},
+		{
+			name: "move to the front",
+			run: func() error {
+				return models.CollectionMovies.Move(&CollectionMovie{CollectionID: collection.ID, MovieID: ids["Alien Resurrection"]}, 1)
+			},
+			want:     []string{"Alien Resurrection", "Alien", "Aliens", "Alien 3"},
+			wantTime: 477,
+		},
+		{
+			name: "move past the end",
+			run: func() error {
+				member := &CollectionMovie{CollectionID: collection.ID, MovieID: ids["Alien Resurrection"]}
+
+				err := models.CollectionMovies.Move(member, 10)
+				if err == nil && member.Position != 4 {
+					t.Errorf("got position %d; want it clamped to 4", member.Position)
+				}
+
+				return err
+			},
+			want:     []string{"Alien", "Aliens", "Alien 3", "Alien Resurrection"},
+			wantTime: 477,
+		},
+		{
+			name: "move back",
+			run: func() error {
+				return models.CollectionMovies.Move(&CollectionMovie{CollectionID: collection.ID, MovieID: ids["Alien"]}, 3)
+			},
+			want:     []string{"Aliens", "Alien 3", "Alien", "Alien Resurrection"},
+			wantTime: 477,
+		},
+		{
+			name: "delete",
+			run: func() error {
+				return models.CollectionMovies.Delete(collection.ID, ids["Alien 3"])
+			},
+			want:     []string{"Aliens", "Alien", "Alien Resurrection"},
+			wantTime: 363,
+		},
+		{
+			name: "add again",
+			run: func() error {
+				member := &CollectionMovie{CollectionID: collection.ID, MovieID: ids["Alien 3"]}
+
+				err := models.CollectionMovies.Insert(member)
+				if err == nil && member.Position != 4 {
+					t.Errorf("got position %d; want 4", member.Position)
+				}
+
+				return err
+			},
+			want:     []string{"Aliens", "Alien", "Alien Resurrection", "Alien 3"},
+			wantTime: 477,
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			err := step.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			movies, err := models.CollectionMovies.GetMovies(collection.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			for _, movie := range movies {
+				titles = append(titles, movie.Title)
+			}
+
+			if !slices.Equal(titles, step.want) {
+				t.Errorf("got %v; want %v", titles, step.want)
+			}
+
+			collection.SetMovies(movies)
+
+			if collection.TotalRuntime != step.wantTime {
+				t.Errorf("got total runtime %d; want %d", collection.TotalRuntime, step.wantTime)
+			}
+		})
+	}
+
+	err = models.CollectionMovies.Insert(&CollectionMovie{CollectionID: collection.ID, MovieID: ids["Alien"]})
+	if !errors.Is(err, ErrDuplicateCollectionMovie) {
+		t.Errorf("got error %v adding a movie twice; want ErrDuplicateCollectionMovie", err)
+	}
+
+	err = models.CollectionMovies.Delete(collection.ID, ids["Alien 3"]+100)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got error %v removing a movie that isn't there; want ErrRecordNotFound", err)
+	}
+}
@@ -0,0 +1,250 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	CreditRoleDirector = "director"
+	CreditRoleCast     = "cast"
+	CreditRoleCrew     = "crew"
+)
+
+var CreditRoles = []string{CreditRoleDirector, CreditRoleCast, CreditRoleCrew}
+
+// Credit links a Person to a Movie. PersonName, MovieTitle and MovieYear are
+// not stored on the credit, they are filled in by the joins when reading
+// credits for a movie or a filmography for a person
+type Credit struct {
+	ID           int64  `json:"id"`
+	MovieID      int64  `json:"movie_id"`
+	PersonID     int64  `json:"person_id"`
+	PersonName   string `json:"person_name,omitempty"`
+	MovieTitle   string `json:"movie_title,omitempty"`
+	MovieYear    int32  `json:"movie_year,omitempty"`
+	Role         string `json:"role"`
+	Job          string `json:"job,omitempty"`
+	Character    string `json:"character,omitempty"`
+	BillingOrder int32  `json:"billing_order"`
+	// Only set when the person is expanded, see ?expand=credits.person
+	Person *Person `json:"person,omitempty"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.CheckError(credit.PersonID > 0, "person_id", validator.Required())
+
+	v.CheckError(credit.Role != "", "role", validator.Required())
+	v.CheckError(validator.PermittedValue(credit.Role, CreditRoles...), "role",
+		validator.NewError(validator.CodeOneOf, "must be one of director, cast or crew", validator.Params{"values": CreditRoles}))
+
+	v.CheckError(len(credit.Job) <= 500, "job", validator.MaxLength(500))
+	v.CheckError(len(credit.Character) <= 500, "character", validator.MaxLength(500))
+
+	// only cast members play a character
+	if credit.Role != CreditRoleCast {
+		v.CheckError(credit.Character == "", "character", validator.NewError(validator.CodeInvalid, "must only be provided for cast credits", nil))
+	}
+
+	v.CheckError(credit.BillingOrder >= 0, "billing_order", validator.NewError(validator.CodeMin, "must not be negative", validator.Params{"min": 0}))
+}
+
+type CreditModel struct {
+	DB *sql.DB
+}
+
+func (m CreditModel) Insert(credit *Credit) error {
+	query := `
+  INSERT INTO movie_credits (movie_id, person_id, role, job, character_name, billing_order)
+  VALUES ($1, $2, $3, $4, $5, $6)
+  RETURNING id
+  `
+
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.Job, credit.Character, credit.BillingOrder}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID)
+}
+
+// The movie id is part of the WHERE clause so a credit can only be removed
+// through the movie it belongs to
+func (m CreditModel) Delete(movieID, creditID int64) error {
+	if movieID < 1 || creditID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+  DELETE FROM movie_credits
+  WHERE id = $1 AND movie_id = $2
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, creditID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Directors first, then cast in billing order, then the crew
+func (m CreditModel) GetAllForMovie(movieID int64) ([]*Credit, error) {
+	query := `
+  SELECT mc.id, mc.movie_id, mc.person_id, p.name, mc.role, mc.job, mc.character_name, mc.billing_order
+  FROM movie_credits mc
+  INNER JOIN people p ON p.id = mc.person_id
+  WHERE mc.movie_id = $1
+  ORDER BY array_position(ARRAY['director', 'cast', 'crew'], mc.role), mc.billing_order, mc.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.PersonName,
+			&credit.Role,
+			&credit.Job,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// GetAllForMovies is GetAllForMovie for a page of movies, keyed by movie id
+func (m CreditModel) GetAllForMovies(movieIDs []int64) (map[int64][]*Credit, error) {
+	query := `
+  SELECT mc.id, mc.movie_id, mc.person_id, p.name, mc.role, mc.job, mc.character_name, mc.billing_order
+  FROM movie_credits mc
+  INNER JOIN people p ON p.id = mc.person_id
+  WHERE mc.movie_id = ANY($1)
+  ORDER BY mc.movie_id, array_position(ARRAY['director', 'cast', 'crew'], mc.role), mc.billing_order, mc.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credits := make(map[int64][]*Credit)
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.PersonName,
+			&credit.Role,
+			&credit.Job,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits[credit.MovieID] = append(credits[credit.MovieID], &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// Filmography is newest first
+func (m CreditModel) GetAllForPerson(personID int64) ([]*Credit, error) {
+	query := `
+  SELECT mc.id, mc.movie_id, mc.person_id, m.title, m.year, mc.role, mc.job, mc.character_name, mc.billing_order
+  FROM movie_credits mc
+  INNER JOIN movies m ON m.id = mc.movie_id
+  WHERE mc.person_id = $1
+  ORDER BY m.year DESC, m.id, mc.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.MovieTitle,
+			&credit.MovieYear,
+			&credit.Role,
+			&credit.Job,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
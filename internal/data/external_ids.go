@@ -0,0 +1,161 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+const (
+	ProviderIMDb = "imdb"
+	ProviderTMDB = "tmdb"
+)
+
+var ExternalProviders = []string{ProviderIMDb, ProviderTMDB}
+
+// Each provider has its own id format, e.g. tt0133093 on IMDb and 603 on TMDB
+var externalIDRX = map[string]*regexp.Regexp{
+	ProviderIMDb: regexp.MustCompile(`^tt\d{7,10}$`),
+	ProviderTMDB: regexp.MustCompile(`^\d{1,10}$`),
+}
+
+// ExternalIDs maps a provider to the movie's id with that provider. It is
+// read as a JSON object built by the movie queries, which is why it
+// implements sql.Scanner
+type ExternalIDs map[string]string
+
+func (e *ExternalIDs) Scan(value any) error {
+	if value == nil {
+		*e = nil
+		return nil
+	}
+
+	var b []byte
+
+	switch value := value.(type) {
+	case []byte:
+		b = value
+	case string:
+		b = []byte(value)
+	default:
+		return fmt.Errorf("cannot scan %T into ExternalIDs", value)
+	}
+
+	ids := ExternalIDs{}
+
+	err := json.Unmarshal(b, &ids)
+	if err != nil {
+		return err
+	}
+
+	// An empty map is dropped from the JSON output by omitempty
+	*e = ids
+	return nil
+}
+
+// externalIDsColumn is the SELECT expression used by the movie queries to
+// read every external id of a movie in one go
+const externalIDsColumn = `(SELECT COALESCE(json_object_agg(provider, external_id), '{}'::json)
+    FROM movie_external_ids WHERE movie_external_ids.movie_id = movies.id)`
+
+func ValidateExternalID(v *validator.Validator, provider, externalID string) {
+	v.CheckError(validator.PermittedValue(provider, ExternalProviders...), "provider",
+		validator.NewError(validator.CodeOneOf, "must be one of imdb or tmdb", validator.Params{"values": ExternalProviders}))
+
+	v.CheckError(externalID != "", "external_id", validator.Required())
+
+	if rx, ok := externalIDRX[provider]; ok {
+		v.CheckError(validator.Matches(externalID, rx), "external_id",
+			validator.NewError(validator.CodeFormat, fmt.Sprintf("must be a valid %s id", provider), validator.Params{"format": provider}))
+	}
+}
+
+type ExternalIDModel struct {
+	DB *sql.DB
+}
+
+// Set links the movie to the id, replacing any id it already had with the
+// same provider
+func (m ExternalIDModel) Set(movieID int64, provider, externalID string) error {
+	query := `
+  INSERT INTO movie_external_ids (movie_id, provider, external_id)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (movie_id, provider) DO UPDATE
+  SET external_id = EXCLUDED.external_id, created_at = NOW()
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, movieID, provider, externalID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_external_ids_provider_external_id_key"`:
+			return ErrDuplicateExternalID
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ExternalIDModel) Delete(movieID int64, provider string) error {
+	query := `
+  DELETE FROM movie_external_ids
+  WHERE movie_id = $1 AND provider = $2
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, provider)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetMovieID finds the movie that has the given id with the provider
+func (m ExternalIDModel) GetMovieID(provider, externalID string) (int64, error) {
+	query := `
+  SELECT movie_id
+  FROM movie_external_ids
+  WHERE provider = $1 AND external_id = $2
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var movieID int64
+
+	err := m.DB.QueryRowContext(ctx, query, provider, externalID).Scan(&movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return movieID, nil
+}
@@ -23,19 +23,31 @@ type Metadata struct {
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
-	v.Check(f.Page > 0, "page", "must be greater than zero")
-	v.Check(f.Page < 10_000_000, "page", "must be a maximum of 10 million")
+	v.CheckError(f.Page > 0, "page", validator.NewError(validator.CodeMin, "must be greater than zero", validator.Params{"min": 1}))
+	v.CheckError(f.Page < 10_000_000, "page", validator.NewError(validator.CodeMax, "must be a maximum of 10 million", validator.Params{"max": 10_000_000}))
 
-	v.Check(f.PageSize > 0, "page_size", "must be great than zero")
-	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	v.CheckError(f.PageSize > 0, "page_size", validator.NewError(validator.CodeMin, "must be great than zero", validator.Params{"min": 1}))
+	v.CheckError(f.PageSize <= 100, "page_size", validator.NewError(validator.CodeMax, "must be a maximum of 100", validator.Params{"max": 100}))
 
-	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+	v.CheckError(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort",
+		validator.NewError(validator.CodeOneOf, "invalid sort value", validator.Params{"values": f.SortSafeList}))
+}
+
+// Sort values exposed by the API which don't match the column name
+var sortColumnAliases = map[string]string{
+	"rating": "average_rating",
 }
 
 func (f Filters) sortColumn() string {
 	for _, safeVale := range f.SortSafeList {
 		if f.Sort == safeVale {
-			return strings.TrimPrefix(f.Sort, "-")
+			column := strings.TrimPrefix(f.Sort, "-")
+
+			if alias, ok := sortColumnAliases[column]; ok {
+				return alias
+			}
+
+			return column
 		}
 	}
 
@@ -0,0 +1,454 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateGenre = errors.New("duplicate genre")
+
+var SlugRX = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+// Movies store the Slug in their genres array. Aliases are lookup keys (see
+// GenreKey) for the other spellings people use for the same genre
+type Genre struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Aliases   []string  `json:"aliases"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.CheckError(genre.Slug != "", "slug", validator.Required())
+	v.CheckError(len(genre.Slug) <= 100, "slug", validator.MaxLength(100))
+	v.CheckError(validator.Matches(genre.Slug, SlugRX), "slug",
+		validator.NewError(validator.CodeFormat, "must only contain lower case letters, digits and single hyphens", validator.Params{"format": "slug"}))
+
+	v.CheckError(genre.Name != "", "name", validator.Required())
+	v.CheckError(len(genre.Name) <= 100, "name", validator.MaxLength(100))
+
+	v.CheckError(len(genre.Aliases) <= 20, "aliases", validator.NewError(validator.CodeMaxItems, "must not contain more than 20 aliases", validator.Params{"max": 20}))
+	v.CheckError(validator.Unique(genre.Aliases), "aliases", validator.NotUnique())
+
+	for _, alias := range genre.Aliases {
+		v.CheckError(alias != "", "aliases", validator.NewError(validator.CodeRequired, "must not contain empty values", nil))
+	}
+}
+
+// GenreKey is the form genres are compared in, lower case with anything that
+// isn't a letter or digit removed. "Sci-Fi", "sci fi" and "SciFi" are all "scifi"
+func GenreKey(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// GenreTaxonomy is an in memory lookup over every genre, loaded once per
+// request with GenreModel.Taxonomy
+type GenreTaxonomy struct {
+	genres []*Genre
+	bySlug map[string]*Genre
+	byKey  map[string]*Genre
+}
+
+func NewGenreTaxonomy(genres []*Genre) *GenreTaxonomy {
+	t := &GenreTaxonomy{
+		genres: genres,
+		bySlug: make(map[string]*Genre, len(genres)),
+		byKey:  make(map[string]*Genre),
+	}
+
+	for _, genre := range genres {
+		t.bySlug[genre.Slug] = genre
+		t.byKey[GenreKey(genre.Slug)] = genre
+		t.byKey[GenreKey(genre.Name)] = genre
+
+		for _, alias := range genre.Aliases {
+			t.byKey[GenreKey(alias)] = genre
+		}
+	}
+
+	return t
+}
+
+func (t *GenreTaxonomy) Exists(slug string) bool {
+	_, ok := t.bySlug[slug]
+	return ok
+}
+
+// Resolve finds the genre a name, slug or alias refers to
+func (t *GenreTaxonomy) Resolve(name string) (*Genre, bool) {
+	genre, ok := t.byKey[GenreKey(name)]
+	return genre, ok
+}
+
+// Canonicalize swaps every genre it recognises for its slug and drops any
+// duplicates that creates. Unknown genres are left as they are so that
+// ValidateMovie can report them
+func (t *GenreTaxonomy) Canonicalize(genres []string) []string {
+	if genres == nil {
+		return nil
+	}
+
+	canonical := make([]string, 0, len(genres))
+	seen := make(map[string]bool, len(genres))
+
+	for _, name := range genres {
+		if genre, ok := t.Resolve(name); ok {
+			name = genre.Slug
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			canonical = append(canonical, name)
+		}
+	}
+
+	return canonical
+}
+
+// Suggest returns up to three slugs that are close to the given name, for
+// "did you mean" messages. Closeness is the edit distance between the keys
+func (t *GenreTaxonomy) Suggest(name string) []string {
+	key := GenreKey(name)
+	if key == "" {
+		return nil
+	}
+
+	// allow roughly one typo for every three characters
+	maxDistance := len(key) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	best := make(map[string]int)
+
+	for candidate, genre := range t.byKey {
+		d := levenshtein(key, candidate)
+
+		// a name that starts the same way is a good suggestion even when the
+		// edit distance is large, e.g. "science" for "science-fiction"
+		if strings.HasPrefix(candidate, key) && len(key) >= 3 {
+			d = 1
+		}
+
+		if d > maxDistance {
+			continue
+		}
+
+		if current, ok := best[genre.Slug]; !ok || d < current {
+			best[genre.Slug] = d
+		}
+	}
+
+	suggestions := make([]string, 0, len(best))
+	for slug := range best {
+		suggestions = append(suggestions, slug)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if best[suggestions[i]] != best[suggestions[j]] {
+			return best[suggestions[i]] < best[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+
+	if len(suggestions) > 3 {
+		suggestions = suggestions[:3]
+	}
+
+	return suggestions
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+
+	if c < a {
+		a = c
+	}
+
+	return a
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// Taxonomy loads every genre. The table is small so this is cheap enough to
+// do for each request that needs it
+func (m GenreModel) Taxonomy() (*GenreTaxonomy, error) {
+	genres, err := m.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGenreTaxonomy(genres), nil
+}
+
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+  SELECT id, created_at, slug, name, aliases, version
+  FROM genres
+  ORDER BY name ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreatedAt,
+			&genre.Slug,
+			&genre.Name,
+			pq.Array(&genre.Aliases),
+			&genre.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+  SELECT id, created_at, slug, name, aliases, version
+  FROM genres
+  WHERE id = $1
+  `
+
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Aliases),
+		&genre.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	query := `
+  INSERT INTO genres (slug, name, aliases)
+  VALUES ($1, $2, $3)
+  RETURNING id, created_at, version
+  `
+
+	args := []any{genre.Slug, genre.Name, pq.Array(genreKeys(genre.Aliases))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The slug can't be changed here as movies refer to it, use Merge to move
+// movies over to a different genre
+func (m GenreModel) Update(genre *Genre) error {
+	query := `
+  UPDATE genres
+  SET name = $1, aliases = $2, version = version + 1
+  WHERE id = $3 AND version = $4
+  RETURNING version
+  `
+
+	args := []any{genre.Name, pq.Array(genreKeys(genre.Aliases)), genre.ID, genre.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Merge folds source into target. Every movie tagged with the source slug is
+// retagged with the target (without duplicating it if the movie already has
+// both), the source slug, name and aliases become aliases of the target so
+// old spellings keep resolving, and the source genre is deleted. Returns the
+// number of movies that were rewritten
+func (m GenreModel) Merge(source, target *Genre) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+  UPDATE movies
+  SET genres = ARRAY(
+    SELECT g
+    FROM unnest(array_replace(movies.genres, $1, $2)) WITH ORDINALITY AS t(g, i)
+    GROUP BY g
+    ORDER BY MIN(i)
+  ),
+  version = version + 1
+  WHERE genres @> ARRAY[$1]`, source.Slug, target.Slug)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	aliases := append([]string{source.Slug, source.Name}, source.Aliases...)
+	aliases = append(aliases, target.Aliases...)
+	aliases = genreKeys(aliases)
+
+	err = tx.QueryRowContext(ctx, `
+  UPDATE genres
+  SET aliases = $1, version = version + 1
+  WHERE id = $2 AND version = $3
+  RETURNING version`, pq.Array(aliases), target.ID, target.Version).Scan(&target.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrEditConflict
+		default:
+			return 0, err
+		}
+	}
+
+	result, err = tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1 AND version = $2`, source.ID, source.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted == 0 {
+		return 0, ErrEditConflict
+	}
+
+	target.Aliases = aliases
+
+	return affected, tx.Commit()
+}
+
+// Aliases are stored as keys, without duplicates and without empty values
+func genreKeys(aliases []string) []string {
+	keys := []string{}
+	seen := make(map[string]bool, len(aliases))
+
+	for _, alias := range aliases {
+		key := GenreKey(alias)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+// The genres migration backfills movies saved before the taxonomy existed.
+// Whatever it creates has to be found again by GenreKey
+func TestGenreBackfillMatchesGenreKey(t *testing.T) {
+	db := testdb.New(t)
+	models := NewModels(db)
+
+	tests := []struct {
+		genre string
+		slug  string
+	}{
+		{"Sci Fi", "science-fiction"},
+		{"HORROR", "horror"},
+		{"Film Noir", "film-noir"},
+		{"Ciencia ficción", ""},
+		{"Драма", ""},
+	}
+
+	movies := make([]*Movie, len(tests))
+
+	for i, tt := range tests {
+		movies[i] = &Movie{Title: tt.genre, Year: 2000, Runtime: 90, Genres: []string{tt.genre}, Status: MovieStatusReleased}
+
+		err := models.Movies.Insert(movies[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migration, err := os.ReadFile(filepath.Join("..", "..", "migrations", "000010_create_genres_table.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(string(migration))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taxonomy, err := models.Genres.Taxonomy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.genre, func(t *testing.T) {
+			movie, err := models.Movies.Get(movies[i].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(movie.Genres) != 1 {
+				t.Fatalf("got genres %v; want one", movie.Genres)
+			}
+
+			genre, ok := taxonomy.Resolve(tt.genre)
+			if !ok {
+				t.Fatalf("%q doesn't resolve to a genre", tt.genre)
+			}
+
+			if genre.Slug != movie.Genres[0] {
+				t.Errorf("got movie genre %q; %q resolves to %q", movie.Genres[0], tt.genre, genre.Slug)
+			}
+
+			if tt.slug != "" && genre.Slug != tt.slug {
+				t.Errorf("got slug %q; want %q", genre.Slug, tt.slug)
+			}
+		})
+	}
+}
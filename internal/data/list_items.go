@@ -0,0 +1,314 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+var ErrDuplicateListItem = errors.New("duplicate list item")
+
+// MovieTitle and MovieYear come from a join with movies when reading
+type ListItem struct {
+	ListID     int64      `json:"list_id"`
+	MovieID    int64      `json:"movie_id"`
+	MovieTitle string     `json:"movie_title,omitempty"`
+	MovieYear  int32      `json:"movie_year,omitempty"`
+	AddedAt    time.Time  `json:"added_at"`
+	Position   int32      `json:"position"`
+	Notes      string     `json:"notes,omitempty"`
+	WatchedOn  *time.Time `json:"watched_on,omitempty"`
+}
+
+func ValidateListItem(v *validator.Validator, item *ListItem) {
+	v.CheckError(item.MovieID > 0, "movie_id", validator.Required())
+	v.CheckError(len(item.Notes) <= 10_000, "notes", validator.MaxLength(10_000))
+
+	if item.WatchedOn != nil {
+		v.CheckError(!item.WatchedOn.After(time.Now()), "watched_on", validator.NewError(validator.CodeFuture, "must not be in the future", nil))
+	}
+}
+
+type ListItemModel struct {
+	DB *sql.DB
+}
+
+// New items go on the end of the list. The list row is locked so that two
+// items added at the same time don't both get the same position
+func (m ListItemModel) Insert(item *ListItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockList(ctx, tx, item.ListID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+  INSERT INTO list_items (list_id, movie_id, position, notes, watched_on)
+  SELECT $1, $2, COALESCE(MAX(position), 0) + 1, $3, $4
+  FROM list_items
+  WHERE list_id = $1
+  RETURNING added_at, position
+  `
+
+	args := []any{item.ListID, item.MovieID, item.Notes, item.WatchedOn}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&item.AddedAt, &item.Position)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "list_items_pkey"`:
+			return ErrDuplicateListItem
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m ListItemModel) Get(listID, movieID int64) (*ListItem, error) {
+	if listID < 1 || movieID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+  SELECT li.list_id, li.movie_id, m.title, m.year, li.added_at, li.position, li.notes, li.watched_on
+  FROM list_items li
+  INNER JOIN movies m ON m.id = li.movie_id
+  WHERE li.list_id = $1 AND li.movie_id = $2
+  `
+
+	var item ListItem
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, listID, movieID).Scan(
+		&item.ListID,
+		&item.MovieID,
+		&item.MovieTitle,
+		&item.MovieYear,
+		&item.AddedAt,
+		&item.Position,
+		&item.Notes,
+		&item.WatchedOn,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &item, nil
+}
+
+// Update saves the notes and watched date and puts the item at the given
+// position, 0 leaves it where it is. Moving an item shuffles the items in
+// between up or down by one, positions past the end of the list are clamped
+// to the last position. It's all one transaction, so an item is never moved
+// without its other changes being saved or the other way round. The unique
+// position constraint is deferred so it is only checked once everything has
+// been moved
+func (m ListItemModel) Update(item *ListItem, position int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockList(ctx, tx, item.ListID)
+	if err != nil {
+		return err
+	}
+
+	var current, last int32
+
+	err = tx.QueryRowContext(ctx, `
+  SELECT li.position, (SELECT MAX(position) FROM list_items WHERE list_id = $1)
+  FROM list_items li
+  WHERE li.list_id = $1 AND li.movie_id = $2`, item.ListID, item.MovieID).Scan(&current, &last)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if position == 0 {
+		position = current
+	}
+
+	if position > last {
+		position = last
+	}
+
+	switch {
+	case position < current:
+		_, err = tx.ExecContext(ctx, `
+  UPDATE list_items
+  SET position = position + 1
+  WHERE list_id = $1 AND position >= $2 AND position < $3`, item.ListID, position, current)
+	case position > current:
+		_, err = tx.ExecContext(ctx, `
+  UPDATE list_items
+  SET position = position - 1
+  WHERE list_id = $1 AND position > $2 AND position <= $3`, item.ListID, current, position)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+  UPDATE list_items
+  SET notes = $1, watched_on = $2, position = $3
+  WHERE list_id = $4 AND movie_id = $5`, item.Notes, item.WatchedOn, position, item.ListID, item.MovieID)
+
+	if err != nil {
+		return err
+	}
+
+	item.Position = position
+
+	return tx.Commit()
+}
+
+// Items after the removed one move up a place so positions stay contiguous
+func (m ListItemModel) Delete(listID, movieID int64) error {
+	if listID < 1 || movieID < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	var position int32
+
+	err = tx.QueryRowContext(ctx, `
+  DELETE FROM list_items
+  WHERE list_id = $1 AND movie_id = $2
+  RETURNING position`, listID, movieID).Scan(&position)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+  UPDATE list_items
+  SET position = position - 1
+  WHERE list_id = $1 AND position > $2`, listID, position)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ListItemModel) GetAllForList(listID int64, filters Filters) ([]*ListItem, Metadata, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(), li.list_id, li.movie_id, m.title, m.year, li.added_at, li.position, li.notes, li.watched_on
+  FROM list_items li
+  INNER JOIN movies m ON m.id = li.movie_id
+  WHERE li.list_id = $1
+  ORDER BY li.%s %s, li.position ASC
+  LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*ListItem{}
+
+	for rows.Next() {
+		var item ListItem
+
+		err := rows.Scan(
+			&totalRecords,
+			&item.ListID,
+			&item.MovieID,
+			&item.MovieTitle,
+			&item.MovieYear,
+			&item.AddedAt,
+			&item.Position,
+			&item.Notes,
+			&item.WatchedOn,
+		)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+func lockList(ctx context.Context, tx *sql.Tx, listID int64) error {
+	var id int64
+
+	err := tx.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
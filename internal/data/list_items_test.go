@@ -0,0 +1,120 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestListItemUpdate(t *testing.T) {
+	models := NewModels(testdb.New(t))
+
+	user := &User{Name: "Alice", Email: "alice@example.com", Activated: true}
+
+	err := user.Password.Set("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := &List{UserID: user.ID, Name: "To watch"}
+
+	err = models.Lists.Insert(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var movieIDs []int64
+
+	for _, title := range []string{"Alien", "Brazil", "Casablanca", "Dune"} {
+		movie := &Movie{Title: title, Year: 1980, Runtime: 100, Genres: []string{"drama"}, Status: "released"}
+
+		err := models.Movies.Insert(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = models.ListItems.Insert(&ListItem{ListID: list.ID, MovieID: movie.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		movieIDs = append(movieIDs, movie.ID)
+	}
+
+	order := func() []int64 {
+		t.Helper()
+
+		items, _, err := models.ListItems.GetAllForList(list.ID, Filters{Page: 1, PageSize: 20, Sort: "position", SortSafeList: []string{"position"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]int64, len(items))
+		for i, item := range items {
+			ids[i] = item.MovieID
+		}
+
+		return ids
+	}
+
+	tests := []struct {
+		name         string
+		movie        int
+		notes        string
+		position     int32
+		wantPosition int32
+		wantOrder    []int
+	}{
+		{name: "notes only", movie: 1, notes: "rewatch", position: 0, wantPosition: 2, wantOrder: []int{0, 1, 2, 3}},
+		{name: "move up", movie: 3, notes: "first", position: 1, wantPosition: 1, wantOrder: []int{3, 0, 1, 2}},
+		{name: "move down", movie: 3, notes: "last", position: 4, wantPosition: 4, wantOrder: []int{0, 1, 2, 3}},
+		{name: "past the end", movie: 0, notes: "clamped", position: 99, wantPosition: 4, wantOrder: []int{1, 2, 3, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := models.ListItems.Get(list.ID, movieIDs[tt.movie])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			item.Notes = tt.notes
+
+			err = models.ListItems.Update(item, tt.position)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if item.Position != tt.wantPosition {
+				t.Errorf("got position %d, want %d", item.Position, tt.wantPosition)
+			}
+
+			saved, err := models.ListItems.Get(list.ID, movieIDs[tt.movie])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if saved.Notes != tt.notes || saved.Position != tt.wantPosition {
+				t.Errorf("saved notes %q at %d, want %q at %d", saved.Notes, saved.Position, tt.notes, tt.wantPosition)
+			}
+
+			got := order()
+			for i, movie := range tt.wantOrder {
+				if got[i] != movieIDs[movie] {
+					t.Fatalf("got order %v, want movies %v of %v", got, tt.wantOrder, movieIDs)
+				}
+			}
+		})
+	}
+
+	err = models.ListItems.Update(&ListItem{ListID: list.ID, MovieID: movieIDs[0] + 100}, 1)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got %v for a missing item, want ErrRecordNotFound", err)
+	}
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+// List is a user's own collection of movies, e.g. a "to watch" list or a
+// curated list they want to share. ShareSlug is only set once the list has
+// been made public, and is only shown to the list's owner
+type List struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `json:"name" validate:"required,max=500"`
+	Description string    `json:"description,omitempty" validate:"max=10000"`
+	Public      bool      `json:"public"`
+	ShareSlug   *string   `json:"share_slug,omitempty"`
+	Version     int32     `json:"version"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	validator.Struct(v, list)
+}
+
+func (l *List) OwnedBy(user *User) bool {
+	return !user.IsAnonymous() && l.UserID == user.ID
+}
+
+// SetPublic flips the visibility and makes sure a public list has a slug.
+// The slug is kept when a list goes private so that old share links start
+// working again if it is made public later
+func (l *List) SetPublic(public bool) error {
+	l.Public = public
+
+	if public && l.ShareSlug == nil {
+		slug, err := generateShareSlug()
+		if err != nil {
+			return err
+		}
+
+		l.ShareSlug = &slug
+	}
+
+	return nil
+}
+
+// 20 random bytes gives a 32 character slug which can't be guessed
+func generateShareSlug() (string, error) {
+	randomBytes := make([]byte, 20)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	slug := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	return strings.ToLower(slug), nil
+}
+
+type ListModel struct {
+	DB *sql.DB
+}
+
+func (m ListModel) Insert(list *List) error {
+	query := `
+  INSERT INTO lists (user_id, name, description, public, share_slug)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id, created_at, version
+  `
+
+	args := []any{list.UserID, list.Name, list.Description, list.Public, list.ShareSlug}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+func (m ListModel) Get(id int64) (*List, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+  SELECT id, user_id, created_at, name, description, public, share_slug, version
+  FROM lists
+  WHERE id = $1
+  `
+
+	return m.get(query, id)
+}
+
+// Only public lists can be found by their slug
+func (m ListModel) GetBySlug(slug string) (*List, error) {
+	query := `
+  SELECT id, user_id, created_at, name, description, public, share_slug, version
+  FROM lists
+  WHERE share_slug = $1 AND public = true
+  `
+
+	return m.get(query, slug)
+}
+
+func (m ListModel) get(query string, arg any) (*List, error) {
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
+		&list.ID,
+		&list.UserID,
+		&list.CreatedAt,
+		&list.Name,
+		&list.Description,
+		&list.Public,
+		&list.ShareSlug,
+		&list.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+func (m ListModel) Update(list *List) error {
+	query := `
+  UPDATE lists
+  SET name = $1, description = $2, public = $3, share_slug = $4, version = version + 1
+  WHERE id = $5 AND version = $6
+  RETURNING version
+  `
+
+	args := []any{
+		list.Name,
+		list.Description,
+		list.Public,
+		list.ShareSlug,
+		list.ID,
+		list.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ListModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+  DELETE FROM lists
+  WHERE id = $1
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m ListModel) GetAllForUser(userID int64, filters Filters) ([]*List, Metadata, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(), id, user_id, created_at, name, description, public, share_slug, version
+  FROM lists
+  WHERE user_id = $1
+  ORDER BY %s %s, id ASC
+  LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	lists := []*List{}
+
+	for rows.Next() {
+		var list List
+
+		err := rows.Scan(
+			&totalRecords,
+			&list.ID,
+			&list.UserID,
+			&list.CreatedAt,
+			&list.Name,
+			&list.Description,
+			&list.Public,
+			&list.ShareSlug,
+			&list.Version,
+		)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return lists, metadata, nil
+}
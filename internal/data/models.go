@@ -11,11 +11,43 @@ var (
 )
 
 type Models struct {
-	Movies MovieModel
+	Movies           MovieModel
+	People           PersonModel
+	Credits          CreditModel
+	Users            UserModel
+	Tokens           TokenModel
+	Ratings          RatingModel
+	Reviews          ReviewModel
+	Lists            ListModel
+	ListItems        ListItemModel
+	Genres           GenreModel
+	Permissions      PermissionModel
+	ExternalIDs      ExternalIDModel
+	Translations     TranslationModel
+	Collections      CollectionModel
+	CollectionMovies CollectionMovieModel
+	Similarities     SimilarityModel
+	MovieEvents      MovieEventModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
+		Movies:           MovieModel{DB: db},
+		People:           PersonModel{DB: db},
+		Credits:          CreditModel{DB: db},
+		Users:            UserModel{DB: db},
+		Tokens:           TokenModel{DB: db},
+		Ratings:          RatingModel{DB: db},
+		Reviews:          ReviewModel{DB: db},
+		Lists:            ListModel{DB: db},
+		ListItems:        ListItemModel{DB: db},
+		Genres:           GenreModel{DB: db},
+		Permissions:      PermissionModel{DB: db},
+		ExternalIDs:      ExternalIDModel{DB: db},
+		Translations:     TranslationModel{DB: db},
+		Collections:      CollectionModel{DB: db},
+		CollectionMovies: CollectionMovieModel{DB: db},
+		Similarities:     SimilarityModel{DB: db},
+		MovieEvents:      MovieEventModel{DB: db},
 	}
 }
@@ -0,0 +1,94 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MovieEventsChannel is the channel the movies trigger notifies on, each
+// payload is a MovieEvent as JSON
+const MovieEventsChannel = "movie_events"
+
+// MovieEvent records that a movie was created, updated or deleted. IDs
+// increase with each change, though two transactions committing at the
+// same time can become visible out of order
+type MovieEvent struct {
+	ID        int64     `json:"id"`
+	MovieID   int64     `json:"movie_id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"-"`
+}
+
+type MovieEventModel struct {
+	DB *sql.DB
+}
+
+// GetAfter returns up to limit events with an id greater than id, oldest
+// first
+func (m MovieEventModel) GetAfter(id int64, limit int) ([]*MovieEvent, error) {
+	query := `
+  SELECT id, movie_id, type, created_at
+  FROM movie_events
+  WHERE id > $1
+  ORDER BY id
+  LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []*MovieEvent{}
+
+	for rows.Next() {
+		var event MovieEvent
+
+		err := rows.Scan(&event.ID, &event.MovieID, &event.Type, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Bounds returns the ids of the oldest and newest events still kept, both
+// are 0 when there are none
+func (m MovieEventModel) Bounds() (oldest, newest int64, err error) {
+	query := `
+  SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0)
+  FROM movie_events`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query).Scan(&oldest, &newest)
+
+	return oldest, newest, err
+}
+
+// DeleteOlderThan prunes the events clients can no longer resume from
+func (m MovieEventModel) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+  DELETE FROM movie_events
+  WHERE created_at < $1`
+
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestMovieEventsTrigger(t *testing.T) {
+	models := NewModels(testdb.New(t))
+
+	user := &User{Name: "Alice", Email: "alice@example.com", Activated: true}
+
+	err := user.Password.Set("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: "released"}
+
+	err = models.Movies.Insert(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last int64
+
+	// expect checks the events recorded since the last call
+	expect := func(step string, want ...string) {
+		t.Helper()
+
+		events, err := models.MovieEvents.GetAfter(last, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, event := range events {
+			if event.MovieID != movie.ID {
+				t.Errorf("%s: got an event for movie %d, want %d", step, event.MovieID, movie.ID)
+			}
+
+			got = append(got, event.Type)
+			last = event.ID
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("%s: got events %q, want %q", step, got, want)
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: got events %q, want %q", step, got, want)
+			}
+		}
+	}
+
+	expect("insert", "created")
+
+	_, err = models.Ratings.Upsert(&Rating{UserID: user.ID, MovieID: movie.ID, Rating: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect("rating")
+
+	// Saved without any edits, only the version changes
+	err = models.Movies.Update(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect("unchanged update")
+
+	movie.Tagline = "The ocean is calling"
+
+	err = models.Movies.Update(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect("update", "updated")
+
+	_, _, err = models.Movies.Delete(movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect("delete", "deleted")
+}
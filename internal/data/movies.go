@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jim-at-jibba/greenlight/internal/validator"
@@ -15,32 +16,148 @@ import (
 type Movie struct {
 	ID       int64     `json:"id"`
 	CreateAt time.Time `json:"-"` // hides value from json always
-	Title    string    `json:"title"`
-	Year     int32     `json:"year,omitempty"` // hides if field has no value
-	Runtime  Runtime   `json:"runtime,omitempty"`
-	Genres   []string  `json:"genres,omitempty"`
+	Title    string    `json:"title" validate:"required,max=500"`
+	Year     int32     `json:"year,omitempty" validate:"required,min=1888"` // hides if field has no value
+	Runtime  Runtime   `json:"runtime,omitempty" validate:"required,min=1"`
+	Genres   []string  `json:"genres,omitempty" validate:"required,min=1,max=5,unique"`
 	Version  int32     `json:"version"`
+	// Maintained by RatingModel, never set from client input
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int32   `json:"rating_count"`
+	// Managed through ExternalIDModel
+	ExternalIDs ExternalIDs `json:"external_ids,omitempty"`
+	// Set by UpdatePoster, PosterKey is the blob store prefix of the images
+	PosterKey string     `json:"-"`
+	Poster    PosterURLs `json:"poster,omitempty"`
+	Overview  string     `json:"overview,omitempty" validate:"max=10000"`
+	Tagline   string     `json:"tagline,omitempty" validate:"max=500"`
+	// BCP 47 tag of the language the movie was made in
+	OriginalLanguage string       `json:"original_language,omitempty" validate:"omitempty,language"`
+	Status           string       `json:"status" validate:"oneof=announced released"`
+	ReleaseDates     ReleaseDates `json:"release_dates,omitempty" validate:"max=250"`
+	// Only set when Localize has swapped in a translation, Language is the
+	// tag of the translated title and OriginalTitle the untranslated one
+	OriginalTitle string `json:"original_title,omitempty"`
+	Language      string `json:"language,omitempty"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// movieColumns is the SELECT list read into movieDest, the movies table must
+// be called movies in the query
+const movieColumns = `movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+    movies.version, movies.average_rating, movies.rating_count, movies.poster_key, movies.poster_urls,
+    movies.overview, movies.tagline, movies.original_language, movies.status, movies.release_dates,
+    ` + externalIDsColumn
 
-	// Use check() method to execute our validation
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
+// movieDest returns the scan destinations matching movieColumns
+func movieDest(movie *Movie) []any {
+	return []any{
+		&movie.ID,
+		&movie.CreateAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+		&movie.AverageRating,
+		&movie.RatingCount,
+		&movie.PosterKey,
+		&movie.Poster,
+		&movie.Overview,
+		&movie.Tagline,
+		&movie.OriginalLanguage,
+		&movie.Status,
+		&movie.ReleaseDates,
+		&movie.ExternalIDs,
+	}
+}
+
+// MovieFields are the fields that can be picked with ?fields=, by JSON name
+var MovieFields = []string{
+	"id", "title", "year", "runtime", "genres", "version", "average_rating", "rating_count",
+	"external_ids", "poster", "overview", "tagline", "original_language", "status", "release_dates",
+}
+
+// movieProjection is movieColumns and movieDest cut down to fields, which
+// must be from MovieFields. The id is always read, and an empty fields
+// reads everything
+func movieProjection(movie *Movie, fields []string) (string, []any) {
+	if len(fields) == 0 {
+		return movieColumns, movieDest(movie)
+	}
+
+	columns := []string{"movies.id"}
+	dest := []any{&movie.ID}
+
+	for _, field := range fields {
+		switch field {
+		case "title":
+			columns, dest = append(columns, "movies.title"), append(dest, &movie.Title)
+		case "year":
+			columns, dest = append(columns, "movies.year"), append(dest, &movie.Year)
+		case "runtime":
+			columns, dest = append(columns, "movies.runtime"), append(dest, &movie.Runtime)
+		case "genres":
+			columns, dest = append(columns, "movies.genres"), append(dest, pq.Array(&movie.Genres))
+		case "version":
+			columns, dest = append(columns, "movies.version"), append(dest, &movie.Version)
+		case "average_rating":
+			columns, dest = append(columns, "movies.average_rating"), append(dest, &movie.AverageRating)
+		case "rating_count":
+			columns, dest = append(columns, "movies.rating_count"), append(dest, &movie.RatingCount)
+		case "external_ids":
+			columns, dest = append(columns, externalIDsColumn), append(dest, &movie.ExternalIDs)
+		case "poster":
+			columns, dest = append(columns, "movies.poster_key", "movies.poster_urls"), append(dest, &movie.PosterKey, &movie.Poster)
+		case "overview":
+			columns, dest = append(columns, "movies.overview"), append(dest, &movie.Overview)
+		case "tagline":
+			columns, dest = append(columns, "movies.tagline"), append(dest, &movie.Tagline)
+		case "original_language":
+			columns, dest = append(columns, "movies.original_language"), append(dest, &movie.OriginalLanguage)
+		case "status":
+			columns, dest = append(columns, "movies.status"), append(dest, &movie.Status)
+		case "release_dates":
+			columns, dest = append(columns, "movies.release_dates"), append(dest, &movie.ReleaseDates)
+		}
+	}
+
+	return strings.Join(columns, ", "), dest
+}
+
+// genres is the current taxonomy, every genre on the movie must be one of its
+// slugs. Run the genres through GenreTaxonomy.Canonicalize first so aliases
+// like "sci-fi" are accepted
+func ValidateMovie(v *validator.Validator, movie *Movie, genres *GenreTaxonomy) {
+	// The validate tags on Movie cover everything that doesn't depend on
+	// another field or the database
+	validator.Struct(v, movie)
+
+	// Only a movie that hasn't come out yet can be dated in the future
+	if movie.Status == MovieStatusAnnounced {
+		max := time.Now().Year() + 10
+		v.CheckError(movie.Year <= int32(max), "year", validator.NewError(validator.CodeMax, "must not be more than 10 years in the future", validator.Params{"max": max, "years": 10}))
+	} else {
+		max := time.Now().Year()
+		v.CheckError(movie.Year <= int32(max), "year", validator.NewError(validator.CodeFuture, "must not be in the future", validator.Params{"max": max}))
+	}
+
+	for i, genre := range movie.Genres {
+		if genres.Exists(genre) {
+			continue
+		}
 
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+		message := fmt.Sprintf("%q is not a known genre", genre)
+		params := validator.Params{"value": genre}
 
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+		if suggestions := genres.Suggest(genre); len(suggestions) > 0 {
+			message += fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, ", "))
+			params["suggestions"] = suggestions
+		}
 
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+		v.Add(validator.Key("genres", i), validator.NewError(validator.CodeUnknown, message, params))
+	}
 
-	// using uique helper
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	validateReleaseDates(v, movie.ReleaseDates)
 }
 
 type MovieModel struct {
@@ -52,12 +169,23 @@ type MovieModel struct {
 // insert
 func (m MovieModel) Insert(movie *Movie) error {
 	query := `
-  INSERT INTO movies (title, year, runtime, genres)
-  VALUES ($1, $2, $3, $4)
+  INSERT INTO movies (title, year, runtime, genres, overview, tagline, original_language, status, release_dates)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
   RETURNING id, created_at, version
   `
 
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []any{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		movie.Overview,
+		movie.Tagline,
+		movie.OriginalLanguage,
+		movie.Status,
+		movie.ReleaseDates,
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 
 	defer cancel()
@@ -65,7 +193,9 @@ func (m MovieModel) Insert(movie *Movie) error {
 	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreateAt, &movie.Version)
 }
 
-func (m MovieModel) Get(id int64) (*Movie, error) {
+// Get reads the movie with the given id. Passing fields (see MovieFields)
+// only reads those columns, leaving the rest of the Movie zero
+func (m MovieModel) Get(id int64, fields ...string) (*Movie, error) {
 	// Because id can never be negative why are we not using uint64 (unsigned).
 	// 2 reasons:
 	// 1. Postgres does not support unsigned ints. Its best to align your go
@@ -76,15 +206,17 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		return nil, ErrRecordNotFound
 	}
 
+	// Struct to hold returned data
+	var movie Movie
+
+	columns, dest := movieProjection(&movie, fields)
+
 	query := `
-  SELECT id, created_at, title, year, runtime, genres, version
+  SELECT ` + columns + `
   FROM movies
   WHERE id = $1
   `
 
-	// Struct to hold returned data
-	var movie Movie
-
 	// 3 second timeout
 	// context.Background - root context
 	// Good article aboiut context
@@ -93,15 +225,7 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreateAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(dest...)
 
 	if err != nil {
 		switch {
@@ -119,8 +243,9 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 func (m MovieModel) Update(movie *Movie) error {
 	query := `
   UPDATE movies
-  SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-  WHERE id = $5 AND version = $6
+  SET title = $1, year = $2, runtime = $3, genres = $4, overview = $5, tagline = $6,
+    original_language = $7, status = $8, release_dates = $9, version = version + 1
+  WHERE id = $10 AND version = $11
   RETURNING version
   `
 
@@ -129,6 +254,11 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Year,
 		movie.Runtime,
 		pq.Array(movie.Genres),
+		movie.Overview,
+		movie.Tagline,
+		movie.OriginalLanguage,
+		movie.Status,
+		movie.ReleaseDates,
 		movie.ID,
 		movie.Version,
 	}
@@ -149,38 +279,60 @@ func (m MovieModel) Update(movie *Movie) error {
 	return nil
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Delete returns the deleted movie's poster key and URLs, the images are in
+// the blob store rather than the database so the caller has to remove them
+func (m MovieModel) Delete(id int64) (string, PosterURLs, error) {
 	if id < 1 {
-		return ErrRecordNotFound
+		return "", nil, ErrRecordNotFound
 	}
 
 	query := `
   DELETE FROM movies
   WHERE id = $1
+  RETURNING poster_key, poster_urls
   `
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
+	var (
+		posterKey  string
+		posterURLs PosterURLs
+	)
 
-	rowsAffected, err := result.RowsAffected()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&posterKey, &posterURLs)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", nil, ErrRecordNotFound
+		default:
+			return "", nil, err
+		}
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
-	}
+	return posterKey, posterURLs, nil
+}
 
-	return nil
+// MovieCriteria narrows down GetAll, zero values match every movie. The
+// release filters look at the release_dates entries, limited to Country when
+// it is set
+type MovieCriteria struct {
+	Title            string
+	Genres           []string
+	PersonID         int64
+	CollectionID     int64
+	Status           string
+	OriginalLanguage string
+	Country          string
+	Certification    string
+	ReleasedFrom     *time.Time
+	ReleasedTo       *time.Time
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// GetAll returns a page of the movies matching criteria. As with Get,
+// passing fields only reads those columns
+func (m MovieModel) GetAll(criteria MovieCriteria, filters Filters, fields ...string) ([]*Movie, Metadata, error) {
 	// (LOWER(title) = LOWER($1) OR $1 = '') = title = title or is skipped because its empty
 	// @> is the postgres array contains function
 
@@ -191,18 +343,51 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	// words
 	// @@ = matches operator
 	// ILIKE and STRPOS() are alternatives to full text search
+	// Translated titles are searched with the text search configuration of
+	// their own language (stored in search_config) so "anneaux" matches
+	// "Les Anneaux" in French the way stemming would for English
+	// EXISTS on movie_credits keeps a movie to a single row even when the
+	// person has more than one credit on it
+	// Country and certification use @> so they can use the GIN index on
+	// release_dates, jsonb_strip_nulls drops whichever one wasn't given
+	columns, _ := movieProjection(&Movie{}, fields)
+
 	query := fmt.Sprintf(`
-  SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+  SELECT count(*) OVER(), %s
   FROM movies
-  WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+  WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = ''
+    OR EXISTS (SELECT 1 FROM movie_translations mt WHERE mt.movie_id = movies.id
+      AND to_tsvector(mt.search_config, mt.title) @@ plainto_tsquery(mt.search_config, $1)))
   AND (genres @> $2 OR $2 = '{}')
+  AND ($3::bigint = 0 OR EXISTS (SELECT 1 FROM movie_credits mc WHERE mc.movie_id = movies.id AND mc.person_id = $3))
+  AND ($4::bigint = 0 OR EXISTS (SELECT 1 FROM collection_movies cm WHERE cm.movie_id = movies.id AND cm.collection_id = $4))
+  AND (status = $5 OR $5 = '')
+  AND (original_language = $6 OR $6 = '')
+  AND (($7 = '' AND $8 = '')
+    OR release_dates @> jsonb_build_array(jsonb_strip_nulls(jsonb_build_object('country', NULLIF($7, ''), 'certification', NULLIF($8, '')))))
+  AND (($9::date IS NULL AND $10::date IS NULL)
+    OR EXISTS (SELECT 1 FROM jsonb_to_recordset(release_dates) AS rd(country text, date date)
+      WHERE (rd.country = $7 OR $7 = '') AND (rd.date >= $9::date OR $9::date IS NULL) AND (rd.date <= $10::date OR $10::date IS NULL)))
   ORDER BY %s %s, id ASC
-  LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+  LIMIT $11 OFFSET $12`, columns, filters.sortColumn(), filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := []any{
+		criteria.Title,
+		pq.Array(criteria.Genres),
+		criteria.PersonID,
+		criteria.CollectionID,
+		criteria.Status,
+		criteria.OriginalLanguage,
+		criteria.Country,
+		criteria.Certification,
+		criteria.ReleasedFrom,
+		criteria.ReleasedTo,
+		filters.limit(),
+		filters.offset(),
+	}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -217,17 +402,9 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	for rows.Next() {
 		var movie Movie
 
-		err := rows.Scan(
-			&totalRecords,
-			&movie.ID,
-			&movie.CreateAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
+		_, dest := movieProjection(&movie, fields)
 
+		err := rows.Scan(append([]any{&totalRecords}, dest...)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -242,3 +419,47 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 	return movies, metadata, nil
 }
+
+// GetAllMissingMetadata returns movies with no runtime or no genres that have
+// at least one external id, so a metadata provider has a chance of filling
+// them in. Results are in id order starting after afterID so callers can page
+// through every candidate, even the ones no provider can help with
+func (m MovieModel) GetAllMissingMetadata(afterID int64, limit int) ([]*Movie, error) {
+	query := `
+  SELECT ` + movieColumns + `
+  FROM movies
+  WHERE (runtime = 0 OR cardinality(genres) = 0)
+  AND EXISTS (SELECT 1 FROM movie_external_ids WHERE movie_external_ids.movie_id = movies.id)
+  AND id > $1
+  ORDER BY id ASC
+  LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movieDest(&movie)...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
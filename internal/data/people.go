@@ -0,0 +1,250 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Person is anyone who can be credited on a movie, a director, an actor
+// or a member of the crew. The role they played lives on the Credit
+type Person struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	Biography string    `json:"biography,omitempty"`
+	BirthYear int32     `json:"birth_year,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.CheckError(person.Name != "", "name", validator.Required())
+	v.CheckError(len(person.Name) <= 500, "name", validator.MaxLength(500))
+
+	v.CheckError(len(person.Biography) <= 10_000, "biography", validator.MaxLength(10_000))
+
+	// birth year is optional so only check it when it has been set
+	if person.BirthYear != 0 {
+		v.CheckError(person.BirthYear >= 1800, "birth_year", validator.NewError(validator.CodeMin, "must be greater than 1800", validator.Params{"min": 1800}))
+		v.CheckError(person.BirthYear <= int32(time.Now().Year()), "birth_year", validator.NewError(validator.CodeFuture, "must not be in the future", validator.Params{"max": time.Now().Year()}))
+	}
+}
+
+type PersonModel struct {
+	DB *sql.DB
+}
+
+func (m PersonModel) Insert(person *Person) error {
+	query := `
+  INSERT INTO people (name, biography, birth_year)
+  VALUES ($1, $2, $3)
+  RETURNING id, created_at, version
+  `
+
+	args := []any{person.Name, person.Biography, person.BirthYear}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+  SELECT id, created_at, name, biography, birth_year, version
+  FROM people
+  WHERE id = $1
+  `
+
+	var person Person
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.Biography,
+		&person.BirthYear,
+		&person.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &person, nil
+}
+
+// GetAllByID returns the people with the given ids keyed by id. Ids that
+// don't exist are left out rather than being an error
+func (m PersonModel) GetAllByID(ids []int64) (map[int64]*Person, error) {
+	query := `
+  SELECT id, created_at, name, biography, birth_year, version
+  FROM people
+  WHERE id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	people := make(map[int64]*Person)
+
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.Biography,
+			&person.BirthYear,
+			&person.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		people[person.ID] = &person
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return people, nil
+}
+
+func (m PersonModel) Update(person *Person) error {
+	query := `
+  UPDATE people
+  SET name = $1, biography = $2, birth_year = $3, version = version + 1
+  WHERE id = $4 AND version = $5
+  RETURNING version
+  `
+
+	args := []any{
+		person.Name,
+		person.Biography,
+		person.BirthYear,
+		person.ID,
+		person.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deleting a person also removes their credits thanks to ON DELETE CASCADE
+func (m PersonModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+  DELETE FROM people
+  WHERE id = $1
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Same full text search approach as MovieModel.GetAll but over the name
+func (m PersonModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+  SELECT count(*) OVER(), id, created_at, name, biography, birth_year, version
+  FROM people
+  WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+  ORDER BY %s %s, id ASC
+  LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(
+			&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.Biography,
+			&person.BirthYear,
+			&person.Version,
+		)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return people, metadata, nil
+}
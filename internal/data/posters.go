@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PosterURLs maps an image size ("original", "w92", "w185"...) to the URL it
+// can be downloaded from. It is stored as JSONB on the movies table
+type PosterURLs map[string]string
+
+func (p PosterURLs) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(p)
+}
+
+func (p *PosterURLs) Scan(value any) error {
+	if value == nil {
+		*p = nil
+		return nil
+	}
+
+	b, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into PosterURLs", value)
+	}
+
+	return json.Unmarshal(b, p)
+}
+
+// UpdatePoster saves the poster fields only, with the same optimistic locking
+// as Update so a poster upload can't silently undo somebody else's edit
+func (m MovieModel) UpdatePoster(movie *Movie) error {
+	query := `
+  UPDATE movies
+  SET poster_key = $1, poster_urls = $2, version = version + 1
+  WHERE id = $3 AND version = $4
+  RETURNING version
+  `
+
+	args := []any{movie.PosterKey, movie.Poster, movie.ID, movie.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+type Rating struct {
+	UserID    int64     `json:"user_id"`
+	MovieID   int64     `json:"movie_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Rating    int32     `json:"rating"`
+}
+
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.CheckError(rating.Rating != 0, "rating", validator.Required())
+	v.CheckError(rating.Rating >= 1 && rating.Rating <= 10, "rating",
+		validator.NewError(validator.CodeBetween, "must be between 1 and 10", validator.Params{"min": 1, "max": 10}))
+}
+
+// RatingSummary is how a movie's ratings are spread. Distribution[i] is the
+// number of ratings of i+1
+type RatingSummary struct {
+	Average      float64   `json:"average"`
+	Count        int32     `json:"count"`
+	Distribution [10]int32 `json:"distribution"`
+}
+
+type RatingModel struct {
+	DB *sql.DB
+}
+
+// Upsert creates the user's rating for a movie or replaces the one they
+// already have. The returned bool is true when a new rating was created.
+//
+// The movie row is locked for the length of the transaction so that the
+// rating_count/rating_total/average_rating aggregates on movies can be moved
+// by the difference this rating makes without another request sneaking in
+func (m RatingModel) Upsert(rating *Rating) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	err = lockMovie(ctx, tx, rating.MovieID)
+	if err != nil {
+		return false, err
+	}
+
+	var previous int32
+
+	err = tx.QueryRowContext(ctx, `
+  SELECT rating
+  FROM ratings
+  WHERE user_id = $1 AND movie_id = $2`, rating.UserID, rating.MovieID).Scan(&previous)
+
+	created := false
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			created = true
+		default:
+			return false, err
+		}
+	}
+
+	query := `
+  INSERT INTO ratings (user_id, movie_id, rating)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (user_id, movie_id) DO UPDATE
+  SET rating = EXCLUDED.rating, updated_at = NOW()
+  RETURNING created_at, updated_at
+  `
+
+	err = tx.QueryRowContext(ctx, query, rating.UserID, rating.MovieID, rating.Rating).Scan(&rating.CreatedAt, &rating.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	countDelta := 0
+	if created {
+		countDelta = 1
+	}
+
+	err = applyRatingDelta(ctx, tx, rating.MovieID, int64(rating.Rating-previous), countDelta)
+	if err != nil {
+		return false, err
+	}
+
+	return created, tx.Commit()
+}
+
+func (m RatingModel) Delete(userID, movieID int64) error {
+	if userID < 1 || movieID < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = lockMovie(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+
+	var previous int32
+
+	err = tx.QueryRowContext(ctx, `
+  DELETE FROM ratings
+  WHERE user_id = $1 AND movie_id = $2
+  RETURNING rating`, userID, movieID).Scan(&previous)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = applyRatingDelta(ctx, tx, movieID, -int64(previous), -1)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func lockMovie(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	var id int64
+
+	err := tx.QueryRowContext(ctx, `SELECT id FROM movies WHERE id = $1 FOR UPDATE`, movieID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The right hand side of SET sees the values from before the update, so the
+// average is worked out from the new total and count in the same statement.
+// version is deliberately left alone, a rating is not an edit of the movie
+// and should not cause edit conflicts for someone updating the title
+func applyRatingDelta(ctx context.Context, tx *sql.Tx, movieID, totalDelta int64, countDelta int) error {
+	query := `
+  UPDATE movies
+  SET rating_total = rating_total + $2,
+      rating_count = rating_count + $3,
+      average_rating = CASE
+        WHEN rating_count + $3 = 0 THEN 0
+        ELSE ROUND((rating_total + $2)::numeric / (rating_count + $3), 2)
+      END
+  WHERE id = $1
+  `
+
+	_, err := tx.ExecContext(ctx, query, movieID, totalDelta, countDelta)
+	return err
+}
+
+// GetSummaries returns a RatingSummary for each of the movies, including
+// the ones nobody has rated yet
+func (m RatingModel) GetSummaries(movieIDs []int64) (map[int64]*RatingSummary, error) {
+	query := `
+  SELECT movie_id, rating, count(*)
+  FROM ratings
+  WHERE movie_id = ANY($1)
+  GROUP BY movie_id, rating`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	summaries := make(map[int64]*RatingSummary, len(movieIDs))
+	for _, id := range movieIDs {
+		summaries[id] = &RatingSummary{}
+	}
+
+	totals := make(map[int64]int64)
+
+	for rows.Next() {
+		var movieID int64
+		var rating, count int32
+
+		err := rows.Scan(&movieID, &rating, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		summary := summaries[movieID]
+		summary.Distribution[rating-1] = count
+		summary.Count += count
+		totals[movieID] += int64(rating) * int64(count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Rounded the same way as movies.average_rating
+	for id, summary := range summaries {
+		if summary.Count > 0 {
+			summary.Average = math.Round(float64(totals[id])/float64(summary.Count)*100) / 100
+		}
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,134 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"golang.org/x/text/language"
+)
+
+const (
+	MovieStatusAnnounced = "announced"
+	MovieStatusReleased  = "released"
+)
+
+var MovieStatuses = []string{MovieStatusAnnounced, MovieStatusReleased}
+
+// Certifications lists the age ratings each country's ratings board uses.
+// A certification can only be given for a country listed here
+var Certifications = map[string][]string{
+	"AU": {"G", "PG", "M", "MA15+", "R18+", "X18+"},
+	"BR": {"L", "10", "12", "14", "16", "18"},
+	"CA": {"G", "PG", "14A", "18A", "R", "A"},
+	"DE": {"0", "6", "12", "16", "18"},
+	"ES": {"A", "7", "12", "16", "18"},
+	"FR": {"U", "10", "12", "16", "18"},
+	"GB": {"U", "PG", "12A", "12", "15", "18", "R18"},
+	"IE": {"G", "PG", "12A", "15A", "16", "18"},
+	"IT": {"T", "6+", "14+", "18+"},
+	"JP": {"G", "PG12", "R15+", "R18+"},
+	"KR": {"ALL", "12", "15", "18"},
+	"NL": {"AL", "6", "9", "12", "14", "16", "18"},
+	"NZ": {"G", "PG", "M", "R13", "R15", "R16", "R18", "R"},
+	"US": {"G", "PG", "PG-13", "R", "NC-17"},
+}
+
+// ReleaseDate is when a movie came out in one country and the age rating it
+// was given there. Date is YYYY-MM-DD
+type ReleaseDate struct {
+	Country       string `json:"country"`
+	Date          string `json:"date"`
+	Certification string `json:"certification,omitempty"`
+}
+
+// ReleaseDates is stored as a JSONB array on the movies table
+type ReleaseDates []ReleaseDate
+
+func (r ReleaseDates) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(r)
+}
+
+func (r *ReleaseDates) Scan(value any) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into ReleaseDates", value)
+	}
+
+	return json.Unmarshal(b, r)
+}
+
+// ValidCountry reports whether code is an upper case ISO 3166-1 alpha-2
+// country code such as "US" or "GB"
+func ValidCountry(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+
+	region, err := language.ParseRegion(code)
+	if err != nil {
+		return false
+	}
+
+	return region.String() == code && region.IsCountry()
+}
+
+func ValidLanguage(tag string) bool {
+	parsed, err := language.Parse(tag)
+	return err == nil && parsed != language.Und
+}
+
+// The "language" validate tag, as used on Movie.OriginalLanguage
+func init() {
+	validator.RegisterRule("language", func(value any, _ string) (validator.Error, bool) {
+		tag, _ := value.(string)
+
+		return validator.NewError(validator.CodeFormat, "must be a valid BCP 47 language tag", validator.Params{"format": "bcp47"}), ValidLanguage(tag)
+	})
+}
+
+func validateReleaseDates(v *validator.Validator, releaseDates ReleaseDates) {
+	seen := make(map[string]bool)
+
+	for i, release := range releaseDates {
+		if !ValidCountry(release.Country) {
+			v.Add(validator.Key("release_dates", i, "country"),
+				validator.NewError(validator.CodeFormat, "country must be an ISO 3166-1 alpha-2 code such as US", validator.Params{"format": "iso3166-1"}))
+			continue
+		}
+
+		if seen[release.Country] {
+			v.Add(validator.Key("release_dates", i, "country"),
+				validator.NewError(validator.CodeDuplicate, fmt.Sprintf("country %s is listed more than once", release.Country), validator.Params{"value": release.Country}))
+			continue
+		}
+
+		seen[release.Country] = true
+
+		if _, err := time.Parse("2006-01-02", release.Date); err != nil {
+			v.Add(validator.Key("release_dates", i, "date"),
+				validator.NewError(validator.CodeFormat, "date must be in the format YYYY-MM-DD", validator.Params{"format": "2006-01-02"}))
+		}
+
+		if release.Certification == "" {
+			continue
+		}
+
+		allowed, ok := Certifications[release.Country]
+
+		switch {
+		case !ok:
+			v.Add(validator.Key("release_dates", i, "certification"),
+				validator.NewError(validator.CodeInvalid, fmt.Sprintf("certifications are not supported for %s", release.Country), validator.Params{"country": release.Country}))
+		case !validator.PermittedValue(release.Certification, allowed...):
+			v.Add(validator.Key("release_dates", i, "certification"),
+				validator.NewError(validator.CodeOneOf, fmt.Sprintf("%q is not a %s certification", release.Certification, release.Country), validator.Params{"value": release.Certification, "country": release.Country, "values": allowed}))
+		}
+	}
+}
@@ -1,55 +1,190 @@
 package data
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+// Runtime is a length in whole minutes
 type Runtime int32
 
+// RuntimeFormat is how a Runtime is written in responses
+type RuntimeFormat string
+
+const (
+	RuntimeMins    RuntimeFormat = "mins"    // "102 mins"
+	RuntimeHM      RuntimeFormat = "hm"      // "1h 42m"
+	RuntimeISO8601 RuntimeFormat = "iso8601" // "PT1H42M"
+)
+
+var RuntimeFormats = []RuntimeFormat{RuntimeMins, RuntimeHM, RuntimeISO8601}
+
+var (
+	// "102", "102m", "102 min", "102 mins", "102 minutes"
+	runtimeMinutesRX = regexp.MustCompile(`^(\d+)\s*(?:m|mins?|minutes?)?$`)
+	// "1h 42m", "1h42m", "1 hr 42 min", "2 hours"
+	runtimeHoursRX = regexp.MustCompile(`^(\d+)\s*(?:h|hrs?|hours?)(?:\s*(\d+)\s*(?:m|mins?|minutes?))?$`)
+	// ISO 8601 durations limited to hours, minutes and seconds, e.g. "PT1H42M"
+	runtimeISO8601RX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+)
+
+// ParseRuntime accepts any of the formats above, case insensitively. ISO 8601
+// seconds are rounded to the nearest minute
+func ParseRuntime(s string) (Runtime, error) {
+	lower := strings.ToLower(strings.TrimSpace(s))
+	upper := strings.ToUpper(lower)
+
+	var hours, minutes, seconds string
+
+	if m := runtimeMinutesRX.FindStringSubmatch(lower); m != nil {
+		minutes = m[1]
+	} else if m := runtimeHoursRX.FindStringSubmatch(lower); m != nil {
+		hours, minutes = m[1], m[2]
+	} else if m := runtimeISO8601RX.FindStringSubmatch(upper); m != nil && upper != "PT" {
+		hours, minutes, seconds = m[1], m[2], m[3]
+	} else {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	var total int64
+
+	for _, part := range []struct {
+		value      string
+		multiplier int64
+	}{{hours, 3600}, {minutes, 60}, {seconds, 1}} {
+		if part.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(part.value, 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+
+		total += n * part.multiplier
+	}
+
+	total = (total + 30) / 60
+
+	if total > 1<<31-1 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return Runtime(total), nil
+}
+
+// Format writes the runtime in the given format, unknown formats fall back
+// to RuntimeMins
+func (r Runtime) Format(format RuntimeFormat) string {
+	switch format {
+	case RuntimeHM:
+		switch {
+		case r < 60:
+			return fmt.Sprintf("%dm", r)
+		case r%60 == 0:
+			return fmt.Sprintf("%dh", r/60)
+		default:
+			return fmt.Sprintf("%dh %dm", r/60, r%60)
+		}
+	case RuntimeISO8601:
+		switch {
+		case r < 60:
+			return fmt.Sprintf("PT%dM", r)
+		case r%60 == 0:
+			return fmt.Sprintf("PT%dH", r/60)
+		default:
+			return fmt.Sprintf("PT%dH%dM", r/60, r%60)
+		}
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
+func (r Runtime) String() string {
+	return r.Format(RuntimeMins)
+}
+
 // When Go is encoding a particular type to JSON, it looks to
 // see if that type satifieds the json.Marshaler interface which
 // has a MarshalJSON() method on it.
-// We can satisfy this interface to do encode types exactly as we want to
+// We can satisfy this interface to do encode types exactly as we want to.
+// A Runtime can't know which RuntimeFormat a request asked for so this is
+// always "<n> mins", responses in another format call Format themselves
 func (r Runtime) MarshalJSON() ([]byte, error) {
-	// Generate string containing movie  runtime in the required format
-	jsonValue := fmt.Sprintf("%d mins", r)
-
 	// strconv.Quote wraps string in dobule quotes
-	quotedJSONValue := strconv.Quote(jsonValue)
-
-	return []byte(quotedJSONValue), nil
+	return []byte(strconv.Quote(r.Format(RuntimeMins))), nil
 }
 
 // Must be pointer receiver so that we modify actual value and not
-// a copy
+// a copy. A bare JSON number is taken as minutes, strings can be in any
+// format ParseRuntime understands
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	// We expect that the incoming JSON value will be a string in the format
-	// "<runtime> mins", we need to remove the double quotes
-	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
-	if err != nil {
-		return ErrInvalidRuntimeFormat
-	}
+	value := string(jsonValue)
 
-	// Split string to isolate part containing number
-	parts := strings.Split(unquotedJSONValue, " ")
+	if value == "null" {
+		return nil
+	}
 
-	// check parts of string is expected format
-	if len(parts) != 2 || parts[1] != "mins" {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	} else if strings.ContainsAny(value, ".eE-") {
+		// Only whole, positive numbers of minutes make sense unquoted
 		return ErrInvalidRuntimeFormat
 	}
 
-	// parse string into int32
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+	runtime, err := ParseRuntime(value)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
+	}
+
+	*r = runtime
+
+	return nil
+}
+
+// MarshalText and UnmarshalText let Runtime be used anywhere text is
+// expected, such as CSV or query strings
+func (r Runtime) MarshalText() ([]byte, error) {
+	return []byte(r.Format(RuntimeMins)), nil
+}
+
+func (r *Runtime) UnmarshalText(text []byte) error {
+	runtime, err := ParseRuntime(string(text))
+	if err != nil {
+		return err
 	}
 
-	*r = Runtime(i)
+	*r = runtime
+
+	return nil
+}
+
+// Value and Scan store the runtime as a plain integer column
+func (r Runtime) Value() (driver.Value, error) {
+	return int64(r), nil
+}
+
+func (r *Runtime) Scan(value any) error {
+	switch value := value.(type) {
+	case int64:
+		*r = Runtime(value)
+	case []byte:
+		i, err := strconv.ParseInt(string(value), 10, 32)
+		if err != nil {
+			return err
+		}
+		*r = Runtime(i)
+	case nil:
+		*r = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Runtime", value)
+	}
 
 	return nil
 }
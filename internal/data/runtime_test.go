@@ -0,0 +1,133 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr error
+	}{
+		{input: "102", want: 102},
+		{input: "102m", want: 102},
+		{input: "102 min", want: 102},
+		{input: "102 mins", want: 102},
+		{input: "102 minutes", want: 102},
+		{input: " 102 MINUTES ", want: 102},
+		{input: "1h 42m", want: 102},
+		{input: "1h42m", want: 102},
+		{input: "1 hr 42 min", want: 102},
+		{input: "2 hours", want: 120},
+		{input: "2h", want: 120},
+		{input: "PT1H42M", want: 102},
+		{input: "pt1h42m", want: 102},
+		{input: "PT102M", want: 102},
+		{input: "PT2H", want: 120},
+		{input: "PT1H41M30S", want: 102},
+		{input: "PT1H41M29S", want: 101},
+		{input: "PT90S", want: 2},
+		{input: "0", want: 0},
+		{input: "", wantErr: ErrInvalidRuntimeFormat},
+		{input: "PT", wantErr: ErrInvalidRuntimeFormat},
+		{input: "P1D", wantErr: ErrInvalidRuntimeFormat},
+		{input: "-5", wantErr: ErrInvalidRuntimeFormat},
+		{input: "1.5 hours", wantErr: ErrInvalidRuntimeFormat},
+		{input: "102 seconds", wantErr: ErrInvalidRuntimeFormat},
+		{input: "1h 42", wantErr: ErrInvalidRuntimeFormat},
+		{input: "mins", wantErr: ErrInvalidRuntimeFormat},
+		{input: "99999999999", wantErr: ErrInvalidRuntimeFormat},
+		{input: "PT99999999H", wantErr: ErrInvalidRuntimeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRuntime(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr error
+	}{
+		{input: `102`, want: 102},
+		{input: `"102"`, want: 102},
+		{input: `"1h 42m"`, want: 102},
+		{input: `"102 minutes"`, want: 102},
+		{input: `"PT1H42M"`, want: 102},
+		{input: `null`, want: 0},
+		{input: `102.5`, wantErr: ErrInvalidRuntimeFormat},
+		{input: `1e2`, wantErr: ErrInvalidRuntimeFormat},
+		{input: `-102`, wantErr: ErrInvalidRuntimeFormat},
+		{input: `"a while"`, wantErr: ErrInvalidRuntimeFormat},
+		{input: `true`, wantErr: ErrInvalidRuntimeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Runtime
+
+			err := got.UnmarshalJSON([]byte(tt.input))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Through encoding/json too, as request bodies are decoded
+	var input struct {
+		Runtime Runtime `json:"runtime"`
+	}
+
+	err := json.Unmarshal([]byte(`{"runtime": "1h 42m"}`), &input)
+	if err != nil || input.Runtime != 102 {
+		t.Errorf("got %d, %v", input.Runtime, err)
+	}
+}
+
+func TestRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		format  RuntimeFormat
+		want    string
+	}{
+		{runtime: 102, format: RuntimeMins, want: "102 mins"},
+		{runtime: 102, format: RuntimeHM, want: "1h 42m"},
+		{runtime: 102, format: RuntimeISO8601, want: "PT1H42M"},
+		{runtime: 45, format: RuntimeHM, want: "45m"},
+		{runtime: 45, format: RuntimeISO8601, want: "PT45M"},
+		{runtime: 120, format: RuntimeHM, want: "2h"},
+		{runtime: 120, format: RuntimeISO8601, want: "PT2H"},
+		{runtime: 102, format: "", want: "102 mins"},
+	}
+
+	for _, tt := range tests {
+		got := tt.runtime.Format(tt.format)
+		if got != tt.want {
+			t.Errorf("Runtime(%d).Format(%q): got %q, want %q", tt.runtime, tt.format, got, tt.want)
+		}
+
+		// Every format reads back as the same runtime
+		parsed, err := ParseRuntime(got)
+		if err != nil || parsed != tt.runtime {
+			t.Errorf("ParseRuntime(%q): got %d, %v, want %d", got, parsed, err, tt.runtime)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SimilarityWeights sets how much each signal counts towards the similarity
+// of two movies. Each signal scores between 0 and 1 and the weights are
+// normalised, so only their relative size matters
+//
+//   - Genres: Jaccard index of the genres arrays
+//   - Year: 1 for the same year falling to 0 at 20 years apart
+//   - Credits: Jaccard index of the people credited on each movie
+//   - Title: pg_trgm similarity of the titles
+type SimilarityWeights struct {
+	Genres  float64
+	Year    float64
+	Credits float64
+	Title   float64
+}
+
+var DefaultSimilarityWeights = SimilarityWeights{Genres: 0.4, Year: 0.1, Credits: 0.3, Title: 0.2}
+
+func (w SimilarityWeights) Validate() error {
+	if w.Genres < 0 || w.Year < 0 || w.Credits < 0 || w.Title < 0 {
+		return errors.New("similarity weights must not be negative")
+	}
+
+	if w.Genres+w.Year+w.Credits+w.Title == 0 {
+		return errors.New("at least one similarity weight must be greater than zero")
+	}
+
+	return nil
+}
+
+func (w SimilarityWeights) args() []any {
+	total := w.Genres + w.Year + w.Credits + w.Title
+
+	return []any{w.Genres / total, w.Year / total, w.Credits / total, w.Title / total}
+}
+
+// similarityQuery scores target against every other movie, aliased as movies
+// so externalIDsColumn can be used. $1 to $4 are the weights from
+// SimilarityWeights.args
+const similarityQuery = `
+  WITH people AS (
+    SELECT movie_id, array_agg(DISTINCT person_id) AS ids
+    FROM movie_credits
+    GROUP BY movie_id
+  )
+  SELECT target.id AS movie_id, movies.id AS similar_movie_id,
+      $1 * array_jaccard(target.genres, movies.genres)
+    + $2 * GREATEST(0, 1 - abs(target.year - movies.year) / 20.0)
+    + $3 * array_jaccard(COALESCE(tp.ids, '{}'), COALESCE(mp.ids, '{}'))
+    + $4 * similarity(target.title, movies.title) AS score
+  FROM movies target
+  INNER JOIN movies ON movies.id <> target.id
+  LEFT JOIN people tp ON tp.movie_id = target.id
+  LEFT JOIN people mp ON mp.movie_id = movies.id`
+
+// SimilarMovie is a movie with how similar it is to the one asked about,
+// between 0 and 1
+type SimilarMovie struct {
+	Score float64 `json:"score"`
+	Movie *Movie  `json:"movie"`
+}
+
+type SimilarityModel struct {
+	DB *sql.DB
+}
+
+// GetSimilar scores every other movie on request. Fine for a small catalog,
+// larger ones should use Refresh and GetPrecomputed
+func (m SimilarityModel) GetSimilar(movieID int64, limit int, weights SimilarityWeights) ([]*SimilarMovie, error) {
+	query := `
+  WITH scored AS (` + similarityQuery + `
+    WHERE target.id = $5
+  )
+  SELECT scored.score, ` + movieColumns + `
+  FROM scored
+  INNER JOIN movies ON movies.id = scored.similar_movie_id
+  WHERE scored.score > 0
+  ORDER BY scored.score DESC, movies.id ASC
+  LIMIT $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := append(weights.args(), movieID, limit)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSimilarMovies(rows)
+}
+
+// GetPrecomputed reads the scores saved by the last Refresh. Movies added
+// since then haven't been scored and get ErrRecordNotFound, callers should
+// fall back to GetSimilar for them
+func (m SimilarityModel) GetPrecomputed(movieID int64, limit int) ([]*SimilarMovie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var refreshed bool
+
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM movie_similarity_refreshes WHERE movie_id = $1)`, movieID).Scan(&refreshed)
+	if err != nil {
+		return nil, err
+	}
+
+	if !refreshed {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+  SELECT s.score, ` + movieColumns + `
+  FROM movie_similarities s
+  INNER JOIN movies ON movies.id = s.similar_movie_id
+  WHERE s.movie_id = $1
+  ORDER BY s.score DESC, movies.id ASC
+  LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSimilarMovies(rows)
+}
+
+// Refresh recomputes the whole similarity table, keeping the best keep
+// matches of each movie. It compares every pair of movies so it takes a ctx
+// from the background job rather than the usual 3 second timeout. Readers
+// see the old scores until the transaction commits
+func (m SimilarityModel) Refresh(ctx context.Context, weights SimilarityWeights, keep int) (int64, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_similarities`)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_similarity_refreshes`)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO movie_similarity_refreshes (movie_id) SELECT id FROM movies`)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+  INSERT INTO movie_similarities (movie_id, similar_movie_id, score)
+  SELECT movie_id, similar_movie_id, score
+  FROM (
+    SELECT *, row_number() OVER (PARTITION BY movie_id ORDER BY score DESC, similar_movie_id ASC) AS rank
+    FROM (` + similarityQuery + `) AS scored
+    WHERE score > 0
+  ) AS ranked
+  WHERE rank <= $5`
+
+	args := append(weights.args(), keep)
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, tx.Commit()
+}
+
+func scanSimilarMovies(rows *sql.Rows) ([]*SimilarMovie, error) {
+	defer rows.Close()
+
+	similar := []*SimilarMovie{}
+
+	for rows.Next() {
+		var movie Movie
+		var score float64
+
+		err := rows.Scan(append([]any{&score}, movieDest(&movie)...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		similar = append(similar, &SimilarMovie{Score: score, Movie: &movie})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return similar, nil
+}
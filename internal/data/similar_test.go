@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+func TestSimilarityWeightsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights SimilarityWeights
+		valid   bool
+	}{
+		{"default", DefaultSimilarityWeights, true},
+		{"one signal", SimilarityWeights{Title: 2}, true},
+		{"negative", SimilarityWeights{Genres: 1, Year: -0.1}, false},
+		{"all zero", SimilarityWeights{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.weights.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("got error %v; want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
+
+// insertSimilarityMovies adds Alien and three movies to compare it with.
+// Aliens shares two of its three genres, Blade Runner shares its director
+func insertSimilarityMovies(t *testing.T, models Models) map[string]int64 {
+	t.Helper()
+
+	ids := map[string]int64{}
+
+	for _, movie := range []*Movie{
+		{Title: "Alien", Year: 1979, Genres: []string{"horror", "science-fiction"}},
+		{Title: "Aliens", Year: 1986, Genres: []string{"action", "horror", "science-fiction"}},
+		{Title: "Blade Runner", Year: 1982, Genres: []string{"thriller"}},
+		{Title: "Up", Year: 2009, Genres: []string{"animation"}},
+	} {
+		movie.Runtime = 110
+		movie.Status = MovieStatusReleased
+
+		err := models.Movies.Insert(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids[movie.Title] = movie.ID
+	}
+
+	person := &Person{Name: "Ridley Scott"}
+
+	err := models.People.Insert(person)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"Alien", "Blade Runner"} {
+		err := models.Credits.Insert(&Credit{MovieID: ids[title], PersonID: person.ID, Role: CreditRoleDirector})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ids
+}
+
+func TestGetSimilarScores(t *testing.T) {
+	models := NewModels(testdb.New(t))
+	ids := insertSimilarityMovies(t, models)
+
+	type match struct {
+		title string
+		score float64
+	}
+
+	tests := []struct {
+		name    string
+		weights SimilarityWeights
+		want    []match
+	}{
+		// Two shared genres out of three
+		{"genres", SimilarityWeights{Genres: 1}, []match{{"Aliens", 2.0 / 3}}},
+		// 1 for the same year falling to 0 at 20 years apart
+		{"year", SimilarityWeights{Year: 1}, []match{{"Blade Runner", 0.85}, {"Aliens", 0.65}}},
+		{"credits", SimilarityWeights{Credits: 1}, []match{{"Blade Runner", 1}}},
+		// Weights are normalised, so doubling them changes nothing
+		{"normalised", SimilarityWeights{Genres: 2, Credits: 2}, []match{{"Blade Runner", 0.5}, {"Aliens", 1.0 / 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similar, err := models.Similarities.GetSimilar(ids["Alien"], 10, tt.weights)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(similar) != len(tt.want) {
+				t.Fatalf("got %d similar movies; want %d", len(similar), len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				got := similar[i]
+				if got.Movie.Title != want.title || math.Abs(got.Score-want.score) > 1e-9 {
+					t.Errorf("got %d: %s %.4f; want %s %.4f", i, got.Movie.Title, got.Score, want.title, want.score)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPrecomputed(t *testing.T) {
+	models := NewModels(testdb.New(t))
+	ids := insertSimilarityMovies(t, models)
+
+	_, err := models.Similarities.GetPrecomputed(ids["Alien"], 10)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("got error %v before a refresh; want ErrRecordNotFound", err)
+	}
+
+	_, err = models.Similarities.Refresh(context.Background(), SimilarityWeights{Genres: 1}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similar, err := models.Similarities.GetPrecomputed(ids["Alien"], 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(similar) != 1 || similar[0].Movie.Title != "Aliens" {
+		t.Errorf("got %d similar movies; want only Aliens", len(similar))
+	}
+
+	// Nothing shares a genre with Up, but it has been scored
+	similar, err = models.Similarities.GetPrecomputed(ids["Up"], 10)
+	if err != nil || len(similar) != 0 {
+		t.Errorf("got %d similar movies and error %v; want none", len(similar), err)
+	}
+
+	movie := &Movie{Title: "Prometheus", Year: 2012, Runtime: 124, Genres: []string{"science-fiction"}, Status: MovieStatusReleased}
+
+	err = models.Movies.Insert(movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.Similarities.GetPrecomputed(movie.ID, 10)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got error %v for a movie added since; want ErrRecordNotFound", err)
+	}
+}
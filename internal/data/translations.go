@@ -0,0 +1,212 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+	"github.com/lib/pq"
+	"golang.org/x/text/language"
+)
+
+// Translation is a movie's title and overview in one language. Language is a
+// canonical BCP 47 tag such as "fr" or "pt-BR"
+type Translation struct {
+	MovieID  int64  `json:"movie_id"`
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Overview string `json:"overview,omitempty"`
+	Version  int32  `json:"version"`
+}
+
+func ValidateTranslation(v *validator.Validator, translation *Translation) {
+	v.CheckError(translation.Title != "", "title", validator.Required())
+	v.CheckError(len(translation.Title) <= 500, "title", validator.MaxLength(500))
+	v.CheckError(len(translation.Overview) <= 10_000, "overview", validator.MaxLength(10_000))
+}
+
+// ParseLanguage turns a client supplied tag into its canonical form, so
+// "EN-gb" and "en-GB" are stored the same way
+func ParseLanguage(v *validator.Validator, key, value string) string {
+	tag, err := language.Parse(value)
+	if err != nil || tag == language.Und {
+		v.Add(key, validator.NewError(validator.CodeFormat, "must be a valid BCP 47 language tag", validator.Params{"format": "bcp47"}))
+		return ""
+	}
+
+	return tag.String()
+}
+
+// Postgres ships text search configurations for these languages, anything
+// else falls back to "simple" which just lower cases the words
+var searchConfigs = map[string]string{
+	"ar": "arabic", "da": "danish", "de": "german", "el": "greek",
+	"en": "english", "es": "spanish", "fi": "finnish", "fr": "french",
+	"hu": "hungarian", "id": "indonesian", "it": "italian", "lt": "lithuanian",
+	"ne": "nepali", "nl": "dutch", "no": "norwegian", "nb": "norwegian",
+	"pt": "portuguese", "ro": "romanian", "ru": "russian", "sv": "swedish",
+	"ta": "tamil", "tr": "turkish",
+}
+
+func searchConfig(tag string) string {
+	base, _ := language.Make(tag).Base()
+
+	if config, ok := searchConfigs[base.String()]; ok {
+		return config
+	}
+
+	return "simple"
+}
+
+// BestTranslation picks the translation that best matches the client's
+// preferences, in the order they came from Accept-Language. It returns nil
+// when nothing is a reasonable match and the original title should be used
+func BestTranslation(preferred []language.Tag, translations []*Translation) *Translation {
+	if len(preferred) == 0 || len(translations) == 0 {
+		return nil
+	}
+
+	// The first supported tag is what the matcher falls back to, und stands
+	// in for the original title
+	supported := []language.Tag{language.Und}
+	for _, t := range translations {
+		supported = append(supported, language.Make(t.Language))
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(preferred...)
+	if confidence == language.No || index == 0 {
+		return nil
+	}
+
+	return translations[index-1]
+}
+
+// Localize swaps in the best translation for the movie. The untranslated
+// title is kept in OriginalTitle so clients can show both
+func Localize(movie *Movie, preferred []language.Tag, translations []*Translation) {
+	best := BestTranslation(preferred, translations)
+	if best == nil {
+		return
+	}
+
+	movie.OriginalTitle = movie.Title
+	movie.Title = best.Title
+	movie.Language = best.Language
+
+	if best.Overview != "" {
+		movie.Overview = best.Overview
+	}
+}
+
+type TranslationModel struct {
+	DB *sql.DB
+}
+
+// Upsert creates the translation or replaces the one for the same language
+func (m TranslationModel) Upsert(translation *Translation) error {
+	query := `
+  INSERT INTO movie_translations (movie_id, language, title, overview, search_config)
+  VALUES ($1, $2, $3, $4, $5)
+  ON CONFLICT (movie_id, language) DO UPDATE
+  SET title = EXCLUDED.title, overview = EXCLUDED.overview, version = movie_translations.version + 1
+  RETURNING version
+  `
+
+	args := []any{
+		translation.MovieID,
+		translation.Language,
+		translation.Title,
+		translation.Overview,
+		searchConfig(translation.Language),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.Version)
+}
+
+func (m TranslationModel) Delete(movieID int64, lang string) error {
+	query := `
+  DELETE FROM movie_translations
+  WHERE movie_id = $1 AND language = $2
+  `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, lang)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m TranslationModel) GetAllForMovie(movieID int64) ([]*Translation, error) {
+	translations, err := m.GetAllForMovies([]int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if translations[movieID] == nil {
+		return []*Translation{}, nil
+	}
+
+	return translations[movieID], nil
+}
+
+// GetAllForMovies loads the translations for a page of movies in one query,
+// keyed by movie id
+func (m TranslationModel) GetAllForMovies(movieIDs []int64) (map[int64][]*Translation, error) {
+	query := `
+  SELECT movie_id, language, title, overview, version
+  FROM movie_translations
+  WHERE movie_id = ANY($1)
+  ORDER BY movie_id, language`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	translations := make(map[int64][]*Translation)
+
+	for rows.Next() {
+		var translation Translation
+
+		err := rows.Scan(
+			&translation.MovieID,
+			&translation.Language,
+			&translation.Title,
+			&translation.Overview,
+			&translation.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		translations[translation.MovieID] = append(translations[translation.MovieID], &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
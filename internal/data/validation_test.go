@@ -0,0 +1,63 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jim-at-jibba/greenlight/internal/validator"
+)
+
+func TestValidationCodes(t *testing.T) {
+	future := time.Now().AddDate(0, 0, 7)
+
+	tests := []struct {
+		name     string
+		validate func(v *validator.Validator)
+		key      string
+		code     string
+	}{
+		{"short password", func(v *validator.Validator) { ValidatePasswordPlaintext(v, "pa55") }, "password", validator.CodeMinLength},
+		{"short token", func(v *validator.Validator) { ValidateTokenPlaintext(v, "ABC") }, "token", validator.CodeFormat},
+		{"birth year too early", func(v *validator.Validator) { ValidatePerson(v, &Person{Name: "A", BirthYear: 1700}) }, "birth_year", validator.CodeMin},
+		{"birth year in the future", func(v *validator.Validator) { ValidatePerson(v, &Person{Name: "A", BirthYear: 3000}) }, "birth_year", validator.CodeFuture},
+		{"unknown role", func(v *validator.Validator) { ValidateCredit(v, &Credit{PersonID: 1, Role: "extra"}) }, "role", validator.CodeOneOf},
+		{"character for crew", func(v *validator.Validator) {
+			ValidateCredit(v, &Credit{PersonID: 1, Role: CreditRoleCrew, Character: "Neo"})
+		}, "character", validator.CodeInvalid},
+		{"negative billing order", func(v *validator.Validator) {
+			ValidateCredit(v, &Credit{PersonID: 1, Role: CreditRoleCast, BillingOrder: -1})
+		}, "billing_order", validator.CodeMin},
+		{"unknown provider", func(v *validator.Validator) { ValidateExternalID(v, "letterboxd", "x") }, "provider", validator.CodeOneOf},
+		{"bad imdb id", func(v *validator.Validator) { ValidateExternalID(v, ProviderIMDb, "x") }, "external_id", validator.CodeFormat},
+		{"bad slug", func(v *validator.Validator) { ValidateGenre(v, &Genre{Slug: "Sci Fi", Name: "Sci-Fi"}) }, "slug", validator.CodeFormat},
+		{"too many aliases", func(v *validator.Validator) {
+			aliases := make([]string, 21)
+			for i := range aliases {
+				aliases[i] = string(rune('a' + i))
+			}
+			ValidateGenre(v, &Genre{Slug: "scifi", Name: "Sci-Fi", Aliases: aliases})
+		}, "aliases", validator.CodeMaxItems},
+		{"empty alias", func(v *validator.Validator) {
+			ValidateGenre(v, &Genre{Slug: "scifi", Name: "Sci-Fi", Aliases: []string{""}})
+		}, "aliases", validator.CodeRequired},
+		{"watched in the future", func(v *validator.Validator) { ValidateListItem(v, &ListItem{MovieID: 1, WatchedOn: &future}) }, "watched_on", validator.CodeFuture},
+		{"rating out of range", func(v *validator.Validator) { ValidateRating(v, &Rating{Rating: 11}) }, "rating", validator.CodeBetween},
+		{"bad language", func(v *validator.Validator) { ParseLanguage(v, "language", "not a tag") }, "language", validator.CodeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			tt.validate(v)
+
+			got := v.Fields[tt.key]
+			if len(got) == 0 {
+				t.Fatalf("got errors %v; want one for %s", v.Fields, tt.key)
+			}
+
+			if got[0].Code != tt.code {
+				t.Errorf("got code %q; want %q", got[0].Code, tt.code)
+			}
+		})
+	}
+}
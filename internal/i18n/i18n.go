@@ -0,0 +1,253 @@
+// Package i18n holds the message catalogs used to localise error and
+// validation messages. Catalogs are JSON files named after their BCP 47 tag,
+// e.g. "fr.json", and map a message key to either a string or, when the
+// message depends on a number, its plural forms:
+//
+//	"validation.genres.max_items": {
+//	  "plural": "max",
+//	  "one": "must not contain more than {max} genre",
+//	  "other": "must not contain more than {max} genres"
+//	}
+//
+// "plural" names the param that picks the form, the forms are the CLDR
+// categories zero, one, two, few, many and other. {name} is replaced with
+// the param of that name
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// Locales are the catalogs shipped with the binary
+//
+//go:embed locales/*.json
+var Locales embed.FS
+
+var placeholderRX = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+var pluralForms = map[string]plural.Form{
+	"zero":  plural.Zero,
+	"one":   plural.One,
+	"two":   plural.Two,
+	"few":   plural.Few,
+	"many":  plural.Many,
+	"other": plural.Other,
+}
+
+type message struct {
+	plural string
+	forms  map[plural.Form]string
+}
+
+func (m *message) UnmarshalJSON(js []byte) error {
+	var text string
+	if err := json.Unmarshal(js, &text); err == nil {
+		m.forms = map[plural.Form]string{plural.Other: text}
+		return nil
+	}
+
+	var forms map[string]string
+	if err := json.Unmarshal(js, &forms); err != nil {
+		return errors.New("must be a string or an object of plural forms")
+	}
+
+	m.plural = forms["plural"]
+	delete(forms, "plural")
+
+	if m.plural == "" {
+		return errors.New(`plural forms need a "plural" param`)
+	}
+
+	if _, ok := forms["other"]; !ok {
+		return errors.New(`plural forms need an "other" form`)
+	}
+
+	m.forms = make(map[plural.Form]string)
+
+	for name, text := range forms {
+		form, ok := pluralForms[name]
+		if !ok {
+			return fmt.Errorf("unknown plural form %q", name)
+		}
+
+		m.forms[form] = text
+	}
+
+	return nil
+}
+
+type catalog struct {
+	tag      language.Tag
+	messages map[string]message
+}
+
+// Bundle is every catalog that was loaded. English is always first and is
+// what everything falls back to
+type Bundle struct {
+	catalogs []*catalog
+	matcher  language.Matcher
+}
+
+// New loads the catalogs shipped with the binary
+func New() (*Bundle, error) {
+	sub, err := fs.Sub(Locales, "locales")
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(sub)
+}
+
+// Load reads every *.json catalog in the root of fsys. It fails on the first
+// catalog that doesn't parse, so a bad translation stops the server starting
+// rather than showing up in a response
+func Load(fsys fs.FS) (*Bundle, error) {
+	names, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{}
+
+	for _, name := range names {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(name), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", name, err)
+		}
+
+		js, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &catalog{tag: tag}
+
+		if err := json.Unmarshal(js, &c.messages); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", name, err)
+		}
+
+		if tag == language.English {
+			b.catalogs = append([]*catalog{c}, b.catalogs...)
+		} else {
+			b.catalogs = append(b.catalogs, c)
+		}
+	}
+
+	if len(b.catalogs) == 0 || b.catalogs[0].tag != language.English {
+		return nil, errors.New("i18n: an en.json catalog is required")
+	}
+
+	tags := make([]language.Tag, len(b.catalogs))
+	for i, c := range b.catalogs {
+		tags[i] = c.tag
+	}
+
+	b.matcher = language.NewMatcher(tags)
+
+	return b, nil
+}
+
+// Localizer picks the catalog that best matches preferred, most preferred
+// first, as returned by language.ParseAcceptLanguage
+func (b *Bundle) Localizer(preferred ...language.Tag) *Localizer {
+	_, index, _ := b.matcher.Match(preferred...)
+
+	return &Localizer{catalog: b.catalogs[index], fallback: b.catalogs[0]}
+}
+
+// Localizer looks up messages in one language, falling back to English for
+// anything that hasn't been translated yet
+type Localizer struct {
+	catalog  *catalog
+	fallback *catalog
+}
+
+// Tag is the language messages are written in, suitable for Content-Language
+func (l *Localizer) Tag() language.Tag {
+	return l.catalog.tag
+}
+
+// Message returns the first of keys found in the catalog with params
+// interpolated, or fallback when none of them are
+func (l *Localizer) Message(keys []string, params map[string]any, fallback string) string {
+	for _, c := range []*catalog{l.catalog, l.fallback} {
+		for _, key := range keys {
+			if m, ok := c.messages[key]; ok {
+				return interpolate(m.text(c.tag, params), params)
+			}
+		}
+	}
+
+	return fallback
+}
+
+func (m message) text(tag language.Tag, params map[string]any) string {
+	if m.plural == "" {
+		return m.forms[plural.Other]
+	}
+
+	n, ok := integer(params[m.plural])
+	if !ok {
+		return m.forms[plural.Other]
+	}
+
+	if n < 0 {
+		n = -n
+	}
+
+	// Only whole numbers are counted, so there are no fraction digits
+	form := plural.Cardinal.MatchPlural(tag, n, 0, 0, 0, 0)
+
+	if text, ok := m.forms[form]; ok {
+		return text
+	}
+
+	return m.forms[plural.Other]
+}
+
+func integer(value any) (int, bool) {
+	switch value := value.(type) {
+	case int:
+		return value, true
+	case int32:
+		return int(value), true
+	case int64:
+		return int(value), true
+	case float64:
+		return int(value), value == float64(int(value))
+	default:
+		return 0, false
+	}
+}
+
+func interpolate(text string, params map[string]any) string {
+	return placeholderRX.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := params[placeholder[1:len(placeholder)-1]]
+		if !ok {
+			return placeholder
+		}
+
+		// Lists are written "a, b, c" rather than Go's "[a b c]"
+		if list := reflect.ValueOf(value); list.Kind() == reflect.Slice {
+			items := make([]string, list.Len())
+			for i := range items {
+				items[i] = fmt.Sprint(list.Index(i).Interface())
+			}
+
+			return strings.Join(items, ", ")
+		}
+
+		return fmt.Sprint(value)
+	})
+}
@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+// Enricher fills in the runtime and genres of movies that are missing them
+// using whichever providers the movie has an external id for. Providers are
+// tried in order and the first one with a value wins
+type Enricher struct {
+	Models    data.Models
+	Providers []Provider
+	BatchSize int
+}
+
+type Result struct {
+	Checked  int `json:"checked"`
+	Enriched int `json:"enriched"`
+}
+
+// Run makes a single pass over every candidate movie. A movie that was edited
+// while it was being enriched is skipped and picked up on the next run
+func (e *Enricher) Run(ctx context.Context) (Result, error) {
+	var result Result
+
+	if len(e.Providers) == 0 {
+		return result, nil
+	}
+
+	batchSize := e.BatchSize
+	if batchSize < 1 {
+		batchSize = 100
+	}
+
+	genres, err := e.Models.Genres.Taxonomy()
+	if err != nil {
+		return result, err
+	}
+
+	var afterID int64
+
+	for {
+		movies, err := e.Models.Movies.GetAllMissingMetadata(afterID, batchSize)
+		if err != nil {
+			return result, err
+		}
+
+		if len(movies) == 0 {
+			return result, nil
+		}
+
+		for _, movie := range movies {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+
+			afterID = movie.ID
+			result.Checked++
+
+			changed, err := e.enrich(ctx, movie, genres)
+			if err != nil {
+				return result, err
+			}
+
+			if !changed {
+				continue
+			}
+
+			err = e.Models.Movies.Update(movie)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrEditConflict):
+					continue
+				default:
+					return result, err
+				}
+			}
+
+			result.Enriched++
+		}
+	}
+}
+
+func (e *Enricher) enrich(ctx context.Context, movie *data.Movie, genres *data.GenreTaxonomy) (bool, error) {
+	changed := false
+
+	for _, provider := range e.Providers {
+		if movie.Runtime != 0 && len(movie.Genres) != 0 {
+			break
+		}
+
+		externalID, ok := movie.ExternalIDs[provider.Name()]
+		if !ok {
+			continue
+		}
+
+		found, err := provider.Lookup(ctx, externalID)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrNotFound):
+				continue
+			default:
+				return false, err
+			}
+		}
+
+		if movie.Runtime == 0 && found.Runtime > 0 {
+			movie.Runtime = found.Runtime
+			changed = true
+		}
+
+		if len(movie.Genres) == 0 {
+			if known := knownGenres(found.Genres, genres); len(known) > 0 {
+				movie.Genres = known
+				changed = true
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// Providers use their own genre names, only the ones that map onto our
+// taxonomy are kept, up to the five a movie is allowed
+func knownGenres(names []string, genres *data.GenreTaxonomy) []string {
+	known := []string{}
+
+	for _, slug := range genres.Canonicalize(names) {
+		if genres.Exists(slug) && len(known) < 5 {
+			known = append(known, slug)
+		}
+	}
+
+	return known
+}
@@ -0,0 +1,220 @@
+package metadata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+	"github.com/jim-at-jibba/greenlight/internal/testdb"
+)
+
+// testProviders is what the providers know: imdb only has runtimes and is
+// tried before tmdb
+const testProviders = `{
+  "tmdb": {
+    "603": {"title": "The Matrix", "runtime": "136 mins", "genres": ["Action", "Sci-Fi", "Cyberpunk"]},
+    "862": {"runtime": "81 mins", "genres": ["animated", "Family"]},
+    "1891": {"runtime": "124 mins", "genres": ["Adventure"]},
+    "9999": {"genres": ["Cyberpunk", "Mumblecore"]}
+  },
+  "imdb": {
+    "tt0133093": {"runtime": "150 mins"},
+    "tt0114709": {"runtime": "999 mins"}
+  }
+}`
+
+func loadTestProviders(t *testing.T) []Provider {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "providers.json")
+
+	err := os.WriteFile(path, []byte(testProviders), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers, err := LoadFileProviders(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return providers
+}
+
+func testTaxonomy() *data.GenreTaxonomy {
+	return data.NewGenreTaxonomy([]*data.Genre{
+		{Slug: "action", Name: "Action"},
+		{Slug: "adventure", Name: "Adventure"},
+		{Slug: "animation", Name: "Animation", Aliases: []string{"animated", "cartoon"}},
+		{Slug: "comedy", Name: "Comedy"},
+		{Slug: "family", Name: "Family"},
+		{Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{"scifi"}},
+	})
+}
+
+func TestEnrich(t *testing.T) {
+	e := &Enricher{Providers: loadTestProviders(t)}
+
+	tests := []struct {
+		name        string
+		movie       data.Movie
+		wantChanged bool
+		wantRuntime data.Runtime
+		wantGenres  []string
+	}{
+		{
+			name:        "missing both",
+			movie:       data.Movie{ExternalIDs: data.ExternalIDs{"tmdb": "862"}},
+			wantChanged: true,
+			wantRuntime: 81,
+			wantGenres:  []string{"animation", "family"},
+		},
+		{
+			name:        "first provider wins",
+			movie:       data.Movie{ExternalIDs: data.ExternalIDs{"imdb": "tt0133093", "tmdb": "603"}},
+			wantChanged: true,
+			wantRuntime: 150,
+			wantGenres:  []string{"action", "science-fiction"},
+		},
+		{
+			name:        "runtime kept",
+			movie:       data.Movie{Runtime: 120, ExternalIDs: data.ExternalIDs{"tmdb": "1891"}},
+			wantChanged: true,
+			wantRuntime: 120,
+			wantGenres:  []string{"adventure"},
+		},
+		{
+			name:        "genres kept",
+			movie:       data.Movie{Genres: []string{"comedy"}, ExternalIDs: data.ExternalIDs{"imdb": "tt0114709", "tmdb": "862"}},
+			wantChanged: true,
+			wantRuntime: 999,
+			wantGenres:  []string{"comedy"},
+		},
+		{
+			name:        "only unknown genres",
+			movie:       data.Movie{ExternalIDs: data.ExternalIDs{"tmdb": "9999"}},
+			wantChanged: false,
+		},
+		{
+			name:        "not found",
+			movie:       data.Movie{ExternalIDs: data.ExternalIDs{"tmdb": "1", "imdb": "tt1"}},
+			wantChanged: false,
+		},
+		{
+			name:        "no external ids",
+			movie:       data.Movie{},
+			wantChanged: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := tt.movie
+
+			changed, err := e.enrich(context.Background(), &movie, testTaxonomy())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changed != tt.wantChanged {
+				t.Errorf("got changed %t, want %t", changed, tt.wantChanged)
+			}
+
+			if movie.Runtime != tt.wantRuntime {
+				t.Errorf("got runtime %d, want %d", movie.Runtime, tt.wantRuntime)
+			}
+
+			if !reflect.DeepEqual(movie.Genres, tt.wantGenres) {
+				t.Errorf("got genres %q, want %q", movie.Genres, tt.wantGenres)
+			}
+		})
+	}
+}
+
+func TestEnricherRun(t *testing.T) {
+	models := data.NewModels(testdb.New(t))
+
+	insert := func(movie data.Movie, externalIDs data.ExternalIDs) *data.Movie {
+		t.Helper()
+
+		movie.Title = "Movie"
+		movie.Year = 1999
+		movie.Status = "released"
+
+		if movie.Genres == nil {
+			movie.Genres = []string{}
+		}
+
+		err := models.Movies.Insert(&movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for provider, id := range externalIDs {
+			err := models.ExternalIDs.Set(movie.ID, provider, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return &movie
+	}
+
+	missing := insert(data.Movie{}, data.ExternalIDs{"imdb": "tt0133093", "tmdb": "603"})
+	hasRuntime := insert(data.Movie{Runtime: 120}, data.ExternalIDs{"tmdb": "1891"})
+	hasGenres := insert(data.Movie{Genres: []string{"comedy"}}, data.ExternalIDs{"tmdb": "862"})
+	complete := insert(data.Movie{Runtime: 90, Genres: []string{"drama"}}, data.ExternalIDs{"tmdb": "13"})
+	unknownGenres := insert(data.Movie{Runtime: 100}, data.ExternalIDs{"imdb": "tt0114709", "tmdb": "9999"})
+	noIDs := insert(data.Movie{}, nil)
+
+	e := &Enricher{Models: models, Providers: loadTestProviders(t), BatchSize: 2}
+
+	result, err := e.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// complete and noIDs aren't candidates
+	if want := (Result{Checked: 4, Enriched: 3}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+
+	tests := []struct {
+		name        string
+		movie       *data.Movie
+		wantRuntime data.Runtime
+		wantGenres  []string
+		wantVersion int32
+	}{
+		{name: "missing", movie: missing, wantRuntime: 150, wantGenres: []string{"action", "science-fiction"}, wantVersion: 2},
+		{name: "has runtime", movie: hasRuntime, wantRuntime: 120, wantGenres: []string{"adventure"}, wantVersion: 2},
+		{name: "has genres", movie: hasGenres, wantRuntime: 81, wantGenres: []string{"comedy"}, wantVersion: 2},
+		{name: "complete", movie: complete, wantRuntime: 90, wantGenres: []string{"drama"}, wantVersion: 1},
+		{name: "unknown genres", movie: unknownGenres, wantRuntime: 100, wantGenres: []string{}, wantVersion: 1},
+		{name: "no external ids", movie: noIDs, wantRuntime: 0, wantGenres: []string{}, wantVersion: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie, err := models.Movies.Get(tt.movie.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if movie.Runtime != tt.wantRuntime {
+				t.Errorf("got runtime %d, want %d", movie.Runtime, tt.wantRuntime)
+			}
+
+			if !reflect.DeepEqual(movie.Genres, tt.wantGenres) {
+				t.Errorf("got genres %q, want %q", movie.Genres, tt.wantGenres)
+			}
+
+			if movie.Version != tt.wantVersion {
+				t.Errorf("got version %d, want %d", movie.Version, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// FileProvider serves metadata from a JSON file instead of a remote API, so
+// enrichment can run offline in development and tests
+type FileProvider struct {
+	name   string
+	movies map[string]*Movie
+}
+
+func (p *FileProvider) Name() string {
+	return p.name
+}
+
+func (p *FileProvider) Lookup(ctx context.Context, externalID string) (*Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	movie, ok := p.movies[externalID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return movie, nil
+}
+
+// LoadFileProviders reads a file keyed by provider and then by external id,
+// and returns one provider per top level key:
+//
+//	{
+//	  "tmdb": {"603": {"title": "The Matrix", "year": 1999, "runtime": "136 mins", "genres": ["action"]}},
+//	  "imdb": {"tt0133093": {"runtime": "136 mins"}}
+//	}
+func LoadFileProviders(path string) ([]Provider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents map[string]map[string]*Movie
+
+	err = json.Unmarshal(b, &contents)
+	if err != nil {
+		return nil, err
+	}
+
+	providers := []Provider{}
+
+	for name, movies := range contents {
+		providers = append(providers, &FileProvider{name: name, movies: movies})
+	}
+
+	// map order is random, sort so providers are always tried in the same order
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name() < providers[j].Name()
+	})
+
+	return providers, nil
+}
@@ -0,0 +1,26 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jim-at-jibba/greenlight/internal/data"
+)
+
+var ErrNotFound = errors.New("metadata not found")
+
+// Movie is what a provider knows about a movie. Any field can be left at its
+// zero value if the provider doesn't have it
+type Movie struct {
+	Title   string       `json:"title"`
+	Year    int32        `json:"year"`
+	Runtime data.Runtime `json:"runtime"`
+	Genres  []string     `json:"genres"`
+}
+
+// Provider looks movies up by their id with an external service. Name must
+// match the provider key used in data.ExternalIDs, e.g. "tmdb"
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, externalID string) (*Movie, error)
+}
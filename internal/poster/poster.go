@@ -0,0 +1,144 @@
+package poster
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	// Registers the decoders used by image.Decode and image.DecodeConfig
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	MinWidth  = 200
+	MinHeight = 300
+	MaxWidth  = 8000
+	MaxHeight = 8000
+)
+
+// Thumbnail widths, named the same way as the TMDB image sizes. The height
+// follows the aspect ratio of the original
+var Sizes = map[string]int{
+	"w92":  92,
+	"w185": 185,
+	"w342": 342,
+	"w500": 500,
+}
+
+var permittedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// ValidationError is a problem with the uploaded image the client can fix,
+// as opposed to something going wrong on our side
+type ValidationError struct {
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+type Image struct {
+	ContentType string
+	Body        []byte
+}
+
+// Poster is the original upload plus every thumbnail that could be made
+// from it. Thumbnails are never scaled up so a small original has fewer sizes
+type Poster struct {
+	Width  int
+	Height int
+	Images map[string]Image
+}
+
+// Process checks the upload really is an image we support by looking at its
+// contents rather than trusting the Content-Type header, checks its
+// dimensions and makes the thumbnails. The dimensions are read from the
+// header before the full decode so a tiny file claiming to be a huge image
+// is rejected before we allocate memory for it
+func Process(body []byte) (*Poster, error) {
+	contentType := http.DetectContentType(body)
+
+	if !permitted(contentType) {
+		return nil, ValidationError{"must be a JPEG, PNG or GIF image"}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, ValidationError{"must be a valid image"}
+	}
+
+	switch {
+	case config.Width < MinWidth || config.Height < MinHeight:
+		return nil, ValidationError{fmt.Sprintf("must be at least %dx%d pixels", MinWidth, MinHeight)}
+	case config.Width > MaxWidth || config.Height > MaxHeight:
+		return nil, ValidationError{fmt.Sprintf("must not be larger than %dx%d pixels", MaxWidth, MaxHeight)}
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, ValidationError{"must be a valid image"}
+	}
+
+	poster := &Poster{
+		Width:  config.Width,
+		Height: config.Height,
+		Images: map[string]Image{
+			"original": {ContentType: contentType, Body: body},
+		},
+	}
+
+	for name, width := range Sizes {
+		if width > config.Width {
+			continue
+		}
+
+		thumbnail, err := resize(src, width)
+		if err != nil {
+			return nil, err
+		}
+
+		poster.Images[name] = Image{ContentType: "image/jpeg", Body: thumbnail}
+	}
+
+	return poster, nil
+}
+
+// resize scales to the given width and encodes as JPEG. JPEG has no alpha
+// channel so the image is drawn onto white first, otherwise transparent PNG
+// areas come out black
+func resize(src image.Image, width int) ([]byte, error) {
+	bounds := src.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+
+	err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func permitted(contentType string) bool {
+	for _, t := range permittedTypes {
+		if contentType == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package poster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	return img
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, testImage(width, height))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	err := jpeg.Encode(&buf, testImage(width, height), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	err := gif.Encode(&buf, testImage(width, height), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// pngHeader is only the signature and IHDR chunk of a PNG, enough for
+// image.DecodeConfig to read dimensions the file doesn't have the pixels for
+func pngHeader(width, height uint32) []byte {
+	var ihdr bytes.Buffer
+
+	ihdr.WriteString("IHDR")
+	binary.Write(&ihdr, binary.BigEndian, width)
+	binary.Write(&ihdr, binary.BigEndian, height)
+	ihdr.Write([]byte{8, 2, 0, 0, 0})
+
+	var buf bytes.Buffer
+
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(ihdr.Len()-4))
+	buf.Write(ihdr.Bytes())
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr.Bytes()))
+
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		sizes       []string
+	}{
+		{"png", encodePNG(t, 400, 600), "image/png", []string{"original", "w185", "w342", "w92"}},
+		{"jpeg", encodeJPEG(t, 600, 900), "image/jpeg", []string{"original", "w185", "w342", "w500", "w92"}},
+		{"gif", encodeGIF(t, 200, 300), "image/gif", []string{"original", "w185", "w92"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster, err := Process(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sizes []string
+			for size := range poster.Images {
+				sizes = append(sizes, size)
+			}
+			sort.Strings(sizes)
+
+			if strings.Join(sizes, ",") != strings.Join(tt.sizes, ",") {
+				t.Errorf("got sizes %v, want %v", sizes, tt.sizes)
+			}
+
+			original := poster.Images["original"]
+			if original.ContentType != tt.contentType || !bytes.Equal(original.Body, tt.body) {
+				t.Errorf("got original %s of %d bytes, want the upload as %s", original.ContentType, len(original.Body), tt.contentType)
+			}
+
+			for size, width := range Sizes {
+				image, ok := poster.Images[size]
+				if !ok {
+					continue
+				}
+
+				if image.ContentType != "image/jpeg" {
+					t.Errorf("%s: got %s, want image/jpeg", size, image.ContentType)
+				}
+
+				config, err := jpeg.DecodeConfig(bytes.NewReader(image.Body))
+				if err != nil {
+					t.Fatalf("%s: %v", size, err)
+				}
+
+				// The aspect ratio of the original is kept
+				if config.Width != width || config.Height != width*poster.Height/poster.Width {
+					t.Errorf("%s: got %dx%d, want %dx%d", size, config.Width, config.Height, width, width*poster.Height/poster.Width)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	valid := encodePNG(t, 400, 600)
+
+	tests := []struct {
+		name    string
+		body    []byte
+		message string
+	}{
+		{"text", []byte("definitely not an image"), "must be a JPEG, PNG or GIF image"},
+		{"html", []byte("<html><body><img src=x></body></html>"), "must be a JPEG, PNG or GIF image"},
+		{"bmp", append([]byte("BM"), make([]byte, 64)...), "must be a JPEG, PNG or GIF image"},
+		{"webp", append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 64)...), "must be a JPEG, PNG or GIF image"},
+		{"png signature only", []byte("\x89PNG\r\n\x1a\n"), "must be a valid image"},
+		{"truncated png", valid[:len(valid)/2], "must be a valid image"},
+		{"too narrow", encodePNG(t, 199, 600), "must be at least 200x300 pixels"},
+		{"too short", encodePNG(t, 400, 299), "must be at least 200x300 pixels"},
+		// Rejected from the header alone, decoding it would need gigabytes
+		{"too wide", pngHeader(8001, 600), "must not be larger than 8000x8000 pixels"},
+		{"too tall", pngHeader(400, 8001), "must not be larger than 8000x8000 pixels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(tt.body)
+
+			var validationError ValidationError
+			if !errors.As(err, &validationError) {
+				t.Fatalf("got %v, want a ValidationError", err)
+			}
+
+			if validationError.Message != tt.message {
+				t.Errorf("got %q, want %q", validationError.Message, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSystemStore(t *testing.T) {
+	dir := t.TempDir()
+	store := &FileSystemStore{Dir: dir, BaseURL: "/v1/blobs/"}
+	ctx := context.Background()
+
+	err := store.Put(ctx, "posters/12/abc/w185", "image/jpeg", []byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Putting the same key again replaces it
+	err = store.Put(ctx, "posters/12/abc/w185", "image/jpeg", []byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "posters", "12", "abc", "w185")
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "second" {
+		t.Errorf("got %q, want %q", body, "second")
+	}
+
+	// Readable by a web server running as another user
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o644 {
+		t.Errorf("got mode %v, want %v", perm, os.FileMode(0o644))
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want 1", len(entries))
+	}
+
+	if got, want := store.URL("posters/12/abc/w185"), "/v1/blobs/posters/12/abc/w185"; got != want {
+		t.Errorf("got URL %q, want %q", got, want)
+	}
+
+	err = store.Delete(ctx, "posters/12/abc/w185")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v after Delete, want os.ErrNotExist", err)
+	}
+
+	// A key that doesn't exist, or no longer does, isn't an error
+	err = store.Delete(ctx, "posters/12/abc/w185")
+	if err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestFileSystemStoreInvalidKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := &FileSystemStore{Dir: filepath.Join(dir, "blobs")}
+	ctx := context.Background()
+
+	for _, key := range []string{"", "/etc/passwd", "../outside", "posters/../../outside", "posters//w185", "posters/./w185", `posters\w185`} {
+		err := store.Put(ctx, key, "image/jpeg", []byte("x"))
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): got %v, want ErrInvalidKey", key, err)
+		}
+
+		err = store.Delete(ctx, key)
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q): got %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("got %d files written, want none", len(entries))
+	}
+}
+
+func TestFileSystemStoreFileServer(t *testing.T) {
+	store := &FileSystemStore{Dir: t.TempDir(), BaseURL: "/v1/blobs"}
+
+	err := store.Put(context.Background(), "posters/12/abc/w185", "image/jpeg", []byte("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.StripPrefix("/v1/blobs", store.FileServer()))
+	defer srv.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/v1/blobs/posters/12/abc/w185", http.StatusOK, "image"},
+		{"/v1/blobs/posters/12/abc/missing", http.StatusNotFound, ""},
+		// Listing a directory would give away every key
+		{"/v1/blobs/", http.StatusNotFound, ""},
+		{"/v1/blobs/posters/", http.StatusNotFound, ""},
+		{"/v1/blobs/posters/12/abc/", http.StatusNotFound, ""},
+		{"/v1/blobs/posters/12", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d", res.StatusCode, tt.status)
+			}
+
+			if tt.body != "" && string(body) != tt.body {
+				t.Errorf("got body %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// s3Stub keeps objects in memory and answers the way S3 does, checking
+// each request carries the SigV4 headers
+type s3Stub struct {
+	t      *testing.T
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]s3Object
+}
+
+type s3Object struct {
+	contentType string
+	body        []byte
+}
+
+func newS3Stub(t *testing.T, bucket string) (*s3Stub, *httptest.Server) {
+	stub := &s3Stub{t: t, bucket: bucket, objects: make(map[string]s3Object)}
+
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	return stub, srv
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.t.Error(err)
+	}
+
+	hash := sha256.Sum256(body)
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != hex.EncodeToString(hash[:]) {
+		s.t.Errorf("%s %s: got X-Amz-Content-Sha256 %q for the body", r.Method, r.URL.Path, got)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ACCESS/") || !strings.Contains(auth, "/eu-west-2/s3/aws4_request") || !strings.Contains(auth, "Signature=") {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "<Error><Code>NoSuchBucket</Code></Error>")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = s3Object{contentType: r.Header.Get("Content-Type"), body: body}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *s3Stub) object(key string) (s3Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object, ok := s.objects[key]
+	return object, ok
+}
+
+func newTestS3Store(srv *httptest.Server, bucket string) *S3Store {
+	return &S3Store{
+		Endpoint:  srv.URL,
+		Bucket:    bucket,
+		Region:    "eu-west-2",
+		AccessKey: "ACCESS",
+		SecretKey: "SECRET",
+		Client:    srv.Client(),
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	stub, srv := newS3Stub(t, "posters")
+	store := newTestS3Store(srv, "posters")
+	ctx := context.Background()
+
+	err := store.Put(ctx, "posters/12/abc/w185", "image/jpeg", []byte("jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object, ok := stub.object("posters/12/abc/w185")
+	if !ok {
+		t.Fatal("object wasn't stored")
+	}
+
+	if object.contentType != "image/jpeg" || string(object.body) != "jpeg" {
+		t.Errorf("got %q %q", object.contentType, object.body)
+	}
+
+	err = store.Delete(ctx, "posters/12/abc/w185")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := stub.object("posters/12/abc/w185"); ok {
+		t.Error("object wasn't deleted")
+	}
+
+	// A key that doesn't exist, or no longer does, isn't an error
+	err = store.Delete(ctx, "posters/12/abc/w185")
+	if err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+
+	err = store.Put(ctx, "../outside", "image/jpeg", []byte("jpeg"))
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("got %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	_, srv := newS3Stub(t, "posters")
+
+	store := newTestS3Store(srv, "posters")
+	store.AccessKey = "WRONG"
+
+	err := store.Put(context.Background(), "posters/1/w185", "image/jpeg", []byte("jpeg"))
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("got %v, want the 403 and S3's error code", err)
+	}
+}
+
+func TestS3StoreURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		store S3Store
+		key   string
+		want  string
+	}{
+		{
+			name:  "endpoint",
+			store: S3Store{Endpoint: "http://localhost:9000/", Bucket: "greenlight"},
+			key:   "posters/12/abc/w185",
+			want:  "http://localhost:9000/greenlight/posters/12/abc/w185",
+		},
+		{
+			name:  "public URL",
+			store: S3Store{Endpoint: "http://localhost:9000", Bucket: "greenlight", PublicURL: "https://cdn.example.com/"},
+			key:   "posters/12/abc/w185",
+			want:  "https://cdn.example.com/posters/12/abc/w185",
+		},
+		{
+			name:  "escaped",
+			store: S3Store{PublicURL: "https://cdn.example.com"},
+			key:   "posters/12/a b+c/w185",
+			want:  "https://cdn.example.com/posters/12/a%20b%2Bc/w185",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.store.URL(tt.key); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore keeps binary objects, like poster images, somewhere they can be
+// served from over HTTP. Keys are slash separated paths such as
+// "posters/12/abc/w185". Deleting a key that doesn't exist is not an error
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, body []byte) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// validKey stops keys escaping the store, e.g. "../../etc/passwd". Our own
+// keys are generated but this is cheap insurance
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,270 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Rule is a custom validation rule that can be used in validate tags once it
+// has been registered. value is the field's value and param whatever came
+// after the "=" in the tag, if anything. It returns ok when the value passes
+type Rule func(value any, param string) (err Error, ok bool)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = make(map[string]Rule)
+)
+
+var builtinRules = map[string]bool{
+	"required": true, "omitempty": true, "dive": true, "min": true, "max": true,
+	"between": true, "oneof": true, "unique": true, "email": true,
+}
+
+// RegisterRule makes rule available to validate tags under name. Like
+// sql.Register it is meant to be called from init and panics if name is
+// already taken
+func RegisterRule(name string, rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	if _, exists := rules[name]; exists || builtinRules[name] {
+		panic("validator: rule " + name + " is already registered")
+	}
+
+	rules[name] = rule
+}
+
+// Struct checks value, a struct or pointer to one, against the validate tags
+// on its fields. Errors are keyed by the field's JSON name, e.g.
+//
+//	Title  string   `json:"title" validate:"required,max=500"`
+//	Genres []string `json:"genres" validate:"required,min=1,max=5,unique"`
+//
+// The rules are:
+//
+//	required     must not be the zero value
+//	omitempty    skip the other rules when the value is the zero value
+//	min=n        numbers must be at least n, strings at least n bytes long
+//	             and slices at least n items long
+//	max=n        the same, but at most
+//	between=n:m  min=n and max=m as a single error
+//	oneof=a b c  must be one of the space separated values
+//	unique       slices must not contain duplicate values
+//	email        must be a valid email address
+//	dive         validate a nested struct, or each struct in a slice, with
+//	             keys such as "release_dates[0].country"
+//
+// and anything added with RegisterRule. A malformed tag is a bug so Struct
+// panics on one
+func Struct(v *Validator, value any) {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validator: Struct called with %T", value))
+	}
+
+	validateStruct(v, "", rv)
+}
+
+func validateStruct(v *Validator, prefix string, rv reflect.Value) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		validateField(v, name, rv.Field(i), tag)
+	}
+}
+
+// Pointers are followed, a nil pointer only fails required
+func validateField(v *Validator, key string, rv reflect.Value, tag string) {
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		if name == "required" {
+			v.CheckError(!rv.IsZero(), key, Required())
+			continue
+		}
+
+		if rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return
+			}
+
+			rv = rv.Elem()
+		}
+
+		switch name {
+		case "omitempty":
+			if rv.IsZero() {
+				return
+			}
+		case "min":
+			checkMin(v, key, rv, number(param, rule))
+		case "max":
+			checkMax(v, key, rv, number(param, rule))
+		case "between":
+			lo, hi, _ := strings.Cut(param, ":")
+			checkBetween(v, key, rv, number(lo, rule), number(hi, rule))
+		case "oneof":
+			values := strings.Fields(param)
+			v.CheckError(PermittedValue(fmt.Sprint(rv.Interface()), values...), key,
+				NewError(CodeOneOf, "must be one of "+strings.Join(values, ", "), Params{"values": values}))
+		case "unique":
+			v.CheckError(uniqueValues(rv, rule), key, NotUnique())
+		case "email":
+			v.CheckError(Matches(rv.String(), EmailRX), key, InvalidEmail())
+		case "dive":
+			dive(v, key, rv)
+		default:
+			rulesMu.RLock()
+			custom, ok := rules[name]
+			rulesMu.RUnlock()
+
+			if !ok {
+				panic("validator: unknown rule " + name)
+			}
+
+			if err, ok := custom(rv.Interface(), param); !ok {
+				v.Add(key, err)
+			}
+		}
+	}
+}
+
+func checkMin(v *Validator, key string, rv reflect.Value, min float64) {
+	switch size, kind := measure(rv); kind {
+	case "length":
+		v.CheckError(size >= min, key, MinLength(int(min)))
+	case "items":
+		v.CheckError(size >= min, key, NewError(CodeMinItems, fmt.Sprintf("must contain at least %v items", min), Params{"min": param(min)}))
+	default:
+		v.CheckError(size >= min, key, NewError(CodeMin, fmt.Sprintf("must be at least %v", min), Params{"min": param(min)}))
+	}
+}
+
+func checkMax(v *Validator, key string, rv reflect.Value, max float64) {
+	switch size, kind := measure(rv); kind {
+	case "length":
+		v.CheckError(size <= max, key, MaxLength(int(max)))
+	case "items":
+		v.CheckError(size <= max, key, NewError(CodeMaxItems, fmt.Sprintf("must not contain more than %v items", max), Params{"max": param(max)}))
+	default:
+		v.CheckError(size <= max, key, NewError(CodeMax, fmt.Sprintf("must be at most %v", max), Params{"max": param(max)}))
+	}
+}
+
+func checkBetween(v *Validator, key string, rv reflect.Value, min, max float64) {
+	size, _ := measure(rv)
+
+	v.CheckError(size >= min && size <= max, key,
+		NewError(CodeBetween, fmt.Sprintf("must be between %v and %v", min, max), Params{"min": param(min), "max": param(max)}))
+}
+
+// measure returns what min and max compare against: the length of strings,
+// the number of items in slices and maps, and the value of numbers
+func measure(rv reflect.Value) (float64, string) {
+	switch rv.Kind() {
+	case reflect.String:
+		return float64(rv.Len()), "length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(rv.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), "number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), "number"
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), "number"
+	default:
+		panic("validator: min, max and between can't be used on " + rv.Type().String())
+	}
+}
+
+func number(s, rule string) float64 {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic("validator: bad parameter in rule " + rule)
+	}
+
+	return n
+}
+
+// param keeps whole numbers as ints so they are written as 500, not 500.0
+func param(n float64) any {
+	if n == float64(int(n)) {
+		return int(n)
+	}
+
+	return n
+}
+
+// uniqueValues looks values up in a map where it can. Slices, maps and
+// anything holding them can't be map keys, those are compared one by one
+func uniqueValues(rv reflect.Value, rule string) bool {
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		panic("validator: " + rule + " can only be used on slices")
+	}
+
+	seen := make(map[any]bool, rv.Len())
+
+	var unhashable []any
+
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+
+		if !item.Comparable() {
+			for _, other := range unhashable {
+				if reflect.DeepEqual(other, item.Interface()) {
+					return false
+				}
+			}
+
+			unhashable = append(unhashable, item.Interface())
+			continue
+		}
+
+		if seen[item.Interface()] {
+			return false
+		}
+
+		seen[item.Interface()] = true
+	}
+
+	return true
+}
+
+func dive(v *Validator, key string, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Struct:
+		validateStruct(v, key, rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			item := reflect.Indirect(rv.Index(i))
+			if item.Kind() == reflect.Struct {
+				validateStruct(v, Key(key, i), item)
+			}
+		}
+	default:
+		panic("validator: dive can't be used on " + rv.Type().String())
+	}
+}
@@ -0,0 +1,288 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// codes returns the codes recorded for each key, in order
+func codes(v *Validator) map[string][]string {
+	got := map[string][]string{}
+
+	for key, errs := range v.Fields {
+		for _, err := range errs {
+			got[key] = append(got[key], err.Code)
+		}
+	}
+
+	return got
+}
+
+func TestStructRules(t *testing.T) {
+	type nested struct {
+		Country string `json:"country" validate:"required,between=2:2"`
+	}
+
+	type input struct {
+		Title    string    `json:"title,omitempty" validate:"required,max=10"`
+		Code     string    `json:"code" validate:"min=3"`
+		Year     int32     `json:"year" validate:"required,min=1888,max=2100"`
+		Rating   float64   `json:"rating" validate:"between=1:10"`
+		Genres   []string  `json:"genres" validate:"required,min=1,max=3,unique"`
+		Status   string    `json:"status" validate:"oneof=announced released"`
+		Email    string    `json:"email" validate:"omitempty,email"`
+		Note     *string   `json:"note" validate:"omitempty,min=2"`
+		Owner    *string   `json:"owner" validate:"required"`
+		Release  nested    `json:"release" validate:"dive"`
+		Releases []nested  `json:"releases" validate:"dive"`
+		Pointers []*nested `json:"pointers" validate:"dive"`
+		NoTag    string    `validate:"required"`
+		Skipped  string    `json:"-" validate:"required"`
+		Ignored  string    `json:"ignored"`
+		private  string    `validate:"required"`
+	}
+
+	note := "x"
+	owner := "alice"
+
+	valid := input{
+		Title:    "Alien",
+		Code:     "abc",
+		Year:     1979,
+		Rating:   8.5,
+		Genres:   []string{"horror", "sci-fi"},
+		Status:   "released",
+		Owner:    &owner,
+		Release:  nested{Country: "GB"},
+		Releases: []nested{{Country: "US"}},
+		Pointers: []*nested{{Country: "FR"}, nil},
+		NoTag:    "set",
+	}
+
+	tests := []struct {
+		name   string
+		change func(in *input)
+		want   map[string][]string
+	}{
+		{"valid", func(in *input) {}, map[string][]string{}},
+		{"required", func(in *input) {
+			in.Title, in.Year, in.Genres, in.Owner, in.NoTag = "", 0, nil, nil, ""
+		}, map[string][]string{
+			"title":  {CodeRequired},
+			"year":   {CodeRequired, CodeMin},
+			"genres": {CodeRequired, CodeMinItems},
+			"owner":  {CodeRequired},
+			"NoTag":  {CodeRequired},
+		}},
+		{"lengths", func(in *input) {
+			in.Title, in.Code = "Alien: Resurrection", "ab"
+		}, map[string][]string{
+			"title": {CodeMaxLength},
+			"code":  {CodeMinLength},
+		}},
+		{"numbers", func(in *input) {
+			in.Year, in.Rating = 1887, 10.5
+		}, map[string][]string{
+			"year":   {CodeMin},
+			"rating": {CodeBetween},
+		}},
+		{"items", func(in *input) {
+			in.Genres = []string{"a", "b", "c", "a"}
+		}, map[string][]string{
+			"genres": {CodeMaxItems, CodeUnique},
+		}},
+		{"empty slice", func(in *input) {
+			in.Genres = []string{}
+		}, map[string][]string{
+			// A non-nil empty slice isn't the zero value
+			"genres": {CodeMinItems},
+		}},
+		{"oneof", func(in *input) {
+			in.Status = "rumoured"
+		}, map[string][]string{
+			"status": {CodeOneOf},
+		}},
+		{"email", func(in *input) {
+			in.Email = "alice"
+		}, map[string][]string{
+			"email": {CodeFormat},
+		}},
+		{"pointer", func(in *input) {
+			in.Note = &note
+		}, map[string][]string{
+			"note": {CodeMinLength},
+		}},
+		{"dive", func(in *input) {
+			in.Release = nested{}
+			in.Releases = []nested{{Country: "US"}, {Country: "USA"}}
+			in.Pointers = []*nested{nil, {Country: ""}}
+		}, map[string][]string{
+			"release.country":     {CodeRequired, CodeBetween},
+			"releases[1].country": {CodeBetween},
+			"pointers[1].country": {CodeRequired, CodeBetween},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid
+			tt.change(&in)
+
+			v := New()
+			Struct(v, &in)
+
+			if got := codes(v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructParams(t *testing.T) {
+	type input struct {
+		Title  string   `json:"title" validate:"max=5"`
+		Genres []string `json:"genres" validate:"min=2"`
+		Rating float64  `json:"rating" validate:"between=0.5:10"`
+		Status string   `json:"status" validate:"oneof=a b"`
+	}
+
+	v := New()
+	Struct(v, input{Title: "Alien 3", Genres: []string{"x"}, Rating: 0, Status: "c"})
+
+	want := map[string][]Error{
+		"title":  {{Code: CodeMaxLength, Message: "must not be more than 5 bytes long", Params: Params{"max": 5}}},
+		"genres": {{Code: CodeMinItems, Message: "must contain at least 2 items", Params: Params{"min": 2}}},
+		// Whole numbers stay ints, the rest are floats
+		"rating": {{Code: CodeBetween, Message: "must be between 0.5 and 10", Params: Params{"min": 0.5, "max": 10}}},
+		"status": {{Code: CodeOneOf, Message: "must be one of a, b", Params: Params{"values": []string{"a", "b"}}}},
+	}
+
+	if !reflect.DeepEqual(v.Fields, want) {
+		t.Errorf("got %v, want %v", v.Fields, want)
+	}
+}
+
+func TestStructUnique(t *testing.T) {
+	type point struct{ X, Y int }
+
+	tests := []struct {
+		name   string
+		values any
+		want   bool
+	}{
+		{"strings", []string{"a", "b"}, true},
+		{"duplicate strings", []string{"a", "b", "a"}, false},
+		{"array", [3]int{1, 2, 1}, false},
+		{"structs", []point{{1, 2}, {2, 1}}, true},
+		{"duplicate structs", []point{{1, 2}, {1, 2}}, false},
+		{"interfaces", []any{1, "1", int64(1)}, true},
+		// Slices and maps can't be map keys, these used to panic
+		{"slices", [][]string{{"a"}, {"a", "b"}}, true},
+		{"duplicate slices", [][]string{{"a", "b"}, {"a", "b"}}, false},
+		{"maps", []map[string]int{{"a": 1}, {"a": 2}}, true},
+		{"duplicate maps", []map[string]int{{"a": 1}, {"a": 1}}, false},
+		{"mixed interfaces", []any{"a", []int{1}, map[string]int{}, []int{2}}, true},
+		{"duplicate mixed interfaces", []any{"a", []int{1}, "b", []int{1}}, false},
+		{"structs holding slices", []any{struct{ S []int }{[]int{1}}, struct{ S []int }{[]int{1}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rv := reflect.ValueOf(tt.values)
+
+			if got := uniqueValues(rv, "unique"); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	type input struct {
+		Tags [][]string `json:"tags" validate:"unique"`
+	}
+
+	v := New()
+	Struct(v, input{Tags: [][]string{{"a"}, {"a"}}})
+
+	if got := codes(v); !reflect.DeepEqual(got, map[string][]string{"tags": {CodeUnique}}) {
+		t.Errorf("got %v, want a unique error for tags", got)
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	RegisterRule("test_even", func(value any, param string) (Error, bool) {
+		return NewError("even", "must be even", nil), value.(int)%2 == 0
+	})
+
+	type input struct {
+		N *int `json:"n" validate:"omitempty,test_even"`
+	}
+
+	odd, even := 3, 4
+
+	for _, tt := range []struct {
+		n    *int
+		want map[string][]string
+	}{
+		{nil, map[string][]string{}},
+		{&even, map[string][]string{}},
+		{&odd, map[string][]string{"n": {"even"}}},
+	} {
+		v := New()
+		Struct(v, input{N: tt.n})
+
+		if got := codes(v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got %v, want %v", got, tt.want)
+		}
+	}
+
+	for _, name := range []string{"test_even", "required", "dive"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q twice didn't panic", name)
+				}
+			}()
+
+			RegisterRule(name, func(any, string) (Error, bool) { return Error{}, true })
+		}()
+	}
+}
+
+func TestStructPanics(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"not a struct", "title", "Struct called with string"},
+		{"unknown rule", struct {
+			A string `validate:"shiny"`
+		}{}, "unknown rule shiny"},
+		{"bad parameter", struct {
+			A string `validate:"max=ten"`
+		}{}, "bad parameter in rule max=ten"},
+		{"min on a bool", struct {
+			A bool `validate:"min=1"`
+		}{}, "can't be used on bool"},
+		{"unique on a string", struct {
+			A string `validate:"unique"`
+		}{}, "unique can only be used on slices"},
+		{"dive on a string", struct {
+			A string `validate:"dive"`
+		}{}, "dive can't be used on string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				p := recover()
+				if p == nil || !strings.Contains(p.(string), tt.want) {
+					t.Errorf("got panic %v, want one containing %q", p, tt.want)
+				}
+			}()
+
+			Struct(New(), tt.value)
+		})
+	}
+}
@@ -1,27 +1,97 @@
 package validator
 
-import "regexp"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+	indexRX = regexp.MustCompile(`\[\d+\]`)
+)
+
+// Error codes are what clients should match on, the messages are only meant
+// for people
+const (
+	CodeInvalid   = "invalid"
+	CodeRequired  = "required"
+	CodeMinLength = "min_length"
+	CodeMaxLength = "max_length"
+	CodeMin       = "min"
+	CodeMax       = "max"
+	CodeMinItems  = "min_items"
+	CodeMaxItems  = "max_items"
+	CodeBetween   = "between"
+	CodeUnique    = "unique"
+	CodeOneOf     = "one_of"
+	CodeFormat    = "format"
+	CodeFuture    = "future"
+	CodeUnknown   = "unknown"
+	CodeDuplicate = "duplicate"
 )
 
+// Params are the values that went into an error, e.g. {"max": 500}, so
+// clients can build their own message
+type Params map[string]any
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Params  Params `json:"params,omitempty"`
+}
+
+func NewError(code, message string, params Params) Error {
+	return Error{Code: code, Message: message, Params: params}
+}
+
+func Required() Error {
+	return NewError(CodeRequired, "must be provided", nil)
+}
+
+func MinLength(min int) Error {
+	return NewError(CodeMinLength, fmt.Sprintf("must be at least %d bytes long", min), Params{"min": min})
+}
+
+func MaxLength(max int) Error {
+	return NewError(CodeMaxLength, fmt.Sprintf("must not be more than %d bytes long", max), Params{"max": max})
+}
+
+func NotUnique() Error {
+	return NewError(CodeUnique, "must not contain duplicate values", nil)
+}
+
+func InvalidEmail() Error {
+	return NewError(CodeFormat, "must be a valid email address", Params{"format": "email"})
+}
+
+// Fields holds every error for a key. Keys can be nested, e.g. "genres[2]" or
+// "release_dates[0].country", see Key.
+//
+// Errors is the flattened form the API has always returned, the first
+// message for each top level field, so an error on "genres[2]" is reported
+// under "genres"
 type Validator struct {
 	Errors map[string]string
+	Fields map[string][]Error
+	keys   []string
 }
 
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{
+		Errors: make(map[string]string),
+		Fields: make(map[string][]Error),
+	}
 }
 
 func (v *Validator) Valid() bool {
-	return len(v.Errors) == 0
+	return len(v.Fields) == 0
 }
 
+// AddError records an error with the generic "invalid" code
 func (v *Validator) AddError(key, message string) {
-	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
-	}
+	v.Add(key, NewError(CodeInvalid, message, nil))
 }
 
 func (v *Validator) Check(ok bool, key, message string) {
@@ -30,6 +100,82 @@ func (v *Validator) Check(ok bool, key, message string) {
 	}
 }
 
+// Add records err against key. A key can have any number of errors but the
+// same error is only recorded once
+func (v *Validator) Add(key string, err Error) {
+	for _, existing := range v.Fields[key] {
+		if existing.Code == err.Code && existing.Message == err.Message {
+			return
+		}
+	}
+
+	if _, exists := v.Fields[key]; !exists {
+		v.keys = append(v.keys, key)
+	}
+
+	v.Fields[key] = append(v.Fields[key], err)
+
+	root := rootKey(key)
+	if _, exists := v.Errors[root]; !exists {
+		v.Errors[root] = err.Message
+	}
+}
+
+func (v *Validator) CheckError(ok bool, key string, err Error) {
+	if !ok {
+		v.Add(key, err)
+	}
+}
+
+// Key builds a nested key, ints become indexes and strings become fields:
+// Key("release_dates", 0, "country") is "release_dates[0].country"
+func Key(field string, path ...any) string {
+	var b strings.Builder
+
+	b.WriteString(field)
+
+	for _, part := range path {
+		switch part := part.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", part)
+		default:
+			fmt.Fprintf(&b, ".%v", part)
+		}
+	}
+
+	return b.String()
+}
+
+// Translate returns a copy of v with every message replaced by translate,
+// e.g. to localise them. Errors are added in their original order so the
+// flattened Errors pick the same messages
+func (v *Validator) Translate(translate func(key string, err Error) string) *Validator {
+	translated := New()
+
+	for _, key := range v.keys {
+		for _, err := range v.Fields[key] {
+			err.Message = translate(key, err)
+			translated.Add(key, err)
+		}
+	}
+
+	return translated
+}
+
+// Path drops the indexes from a key, so every element of a list shares one
+// path: "release_dates[0].country" is "release_dates.country"
+func Path(key string) string {
+	return indexRX.ReplaceAllString(key, "")
+}
+
+func rootKey(key string) string {
+	if i := strings.IndexAny(key, "[."); i > 0 {
+		return key[:i]
+	}
+
+	return key
+}
+
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	for i := range permittedValues {
 		if value == permittedValues[i] {
@@ -0,0 +1,154 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidatorAdd(t *testing.T) {
+	v := New()
+
+	if !v.Valid() {
+		t.Fatal("a new validator isn't valid")
+	}
+
+	v.Add("genres[2]", NewError(CodeUnknown, `"scifi" is not known`, Params{"value": "scifi"}))
+	v.Add("genres[2]", NewError(CodeUnknown, `"scifi" is not known`, Params{"value": "scifi"}))
+	v.Add("genres", NotUnique())
+	v.CheckError(true, "title", Required())
+	v.CheckError(false, "title", Required())
+	v.Check(false, "title", "must be interesting")
+	v.AddError("release_dates[0].country", "must be a country")
+
+	if v.Valid() {
+		t.Fatal("got valid, want errors")
+	}
+
+	wantFields := map[string][]Error{
+		// The same error is only recorded once
+		"genres[2]":                {{Code: CodeUnknown, Message: `"scifi" is not known`, Params: Params{"value": "scifi"}}},
+		"genres":                   {{Code: CodeUnique, Message: "must not contain duplicate values"}},
+		"title":                    {{Code: CodeRequired, Message: "must be provided"}, {Code: CodeInvalid, Message: "must be interesting"}},
+		"release_dates[0].country": {{Code: CodeInvalid, Message: "must be a country"}},
+	}
+
+	if !reflect.DeepEqual(v.Fields, wantFields) {
+		t.Errorf("got fields %v, want %v", v.Fields, wantFields)
+	}
+
+	// Errors has the first message for each top level field
+	wantErrors := map[string]string{
+		"genres":        `"scifi" is not known`,
+		"title":         "must be provided",
+		"release_dates": "must be a country",
+	}
+
+	if !reflect.DeepEqual(v.Errors, wantErrors) {
+		t.Errorf("got errors %v, want %v", v.Errors, wantErrors)
+	}
+}
+
+func TestErrorConstructors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  Error
+		want Error
+	}{
+		{"Required", Required(), Error{Code: "required", Message: "must be provided"}},
+		{"MaxLength", MaxLength(500), Error{Code: "max_length", Message: "must not be more than 500 bytes long", Params: Params{"max": 500}}},
+		{"NotUnique", NotUnique(), Error{Code: "unique", Message: "must not contain duplicate values"}},
+		{"InvalidEmail", InvalidEmail(), Error{Code: "format", Message: "must be a valid email address", Params: Params{"format": "email"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.err, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyAndPath(t *testing.T) {
+	tests := []struct {
+		field string
+		path  []any
+		key   string
+		want  string
+	}{
+		{"title", nil, "title", "title"},
+		{"genres", []any{2}, "genres[2]", "genres"},
+		{"release_dates", []any{0, "country"}, "release_dates[0].country", "release_dates.country"},
+		{"a", []any{1, 2, "b", 3}, "a[1][2].b[3]", "a.b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			key := Key(tt.field, tt.path...)
+			if key != tt.key {
+				t.Errorf("got key %q, want %q", key, tt.key)
+			}
+
+			if got := Path(key); got != tt.want {
+				t.Errorf("got path %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	v := New()
+	v.Add("title", Required())
+	v.Add("genres[1]", NewError(CodeUnknown, "not known", Params{"value": "x"}))
+	v.Add("genres", NotUnique())
+
+	translated := v.Translate(func(key string, err Error) string {
+		return strings.ToUpper(key + ": " + err.Code)
+	})
+
+	if got := translated.Fields["title"][0].Message; got != "TITLE: REQUIRED" {
+		t.Errorf("got %q, want %q", got, "TITLE: REQUIRED")
+	}
+
+	// Codes and params are left alone
+	if got := translated.Fields["genres[1]"][0]; got.Code != CodeUnknown || got.Params["value"] != "x" {
+		t.Errorf("got %+v, want the code and params kept", got)
+	}
+
+	// The flattened error for genres is still the one added first
+	if got := translated.Errors["genres"]; got != "GENRES[1]: UNKNOWN" {
+		t.Errorf("got %q, want %q", got, "GENRES[1]: UNKNOWN")
+	}
+
+	// The original isn't changed
+	if got := v.Fields["title"][0].Message; got != "must be provided" {
+		t.Errorf("original changed to %q", got)
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	if !PermittedValue("cast", "director", "cast") || PermittedValue("writer", "director", "cast") {
+		t.Error("PermittedValue")
+	}
+
+	if !Unique([]string{"a", "b"}) || Unique([]string{"a", "b", "a"}) || !Unique([]int{}) {
+		t.Error("Unique")
+	}
+
+	emails := map[string]bool{
+		"alice@example.com":       true,
+		"alice.smith+tag@a.co.uk": true,
+		"alice":                   false,
+		"alice@":                  false,
+		"@example.com":            false,
+		"alice@-example.com":      false,
+		"alice smith@example.com": false,
+	}
+
+	for email, want := range emails {
+		if got := Matches(email, EmailRX); got != want {
+			t.Errorf("Matches(%q, EmailRX): got %v, want %v", email, got, want)
+		}
+	}
+}
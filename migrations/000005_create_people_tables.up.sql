@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS people (
+    id BIGSERIAL PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name TEXT NOT NULL,
+    biography TEXT NOT NULL DEFAULT '',
+    birth_year INTEGER NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+/* A person can appear more than once on a movie (e.g. director and cast) */
+/* so the credit has its own id rather than a (movie_id, person_id) key */
+CREATE TABLE IF NOT EXISTS movie_credits (
+    id BIGSERIAL PRIMARY KEY,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    person_id BIGINT NOT NULL REFERENCES people ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    job TEXT NOT NULL DEFAULT '',
+    character_name TEXT NOT NULL DEFAULT '',
+    billing_order INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT movie_credits_role_check CHECK (role IN ('director', 'cast', 'crew')),
+    CONSTRAINT movie_credits_billing_order_check CHECK (billing_order >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people
+USING gin(to_tsvector('simple', name));
+CREATE INDEX IF NOT EXISTS movie_credits_movie_id_idx ON movie_credits (movie_id);
+CREATE INDEX IF NOT EXISTS movie_credits_person_id_idx ON movie_credits (person_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS movies_average_rating_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS average_rating;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_total;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS ratings;
@@ -0,0 +1,31 @@
+/* The primary key gives us one rating per user per movie */
+CREATE TABLE IF NOT EXISTS ratings (
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    rating INTEGER NOT NULL,
+    PRIMARY KEY (user_id, movie_id),
+    CONSTRAINT ratings_rating_check CHECK (rating BETWEEN 1 AND 10)
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    body TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT reviews_user_id_movie_id_key UNIQUE (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS ratings_movie_id_idx ON ratings (movie_id);
+CREATE INDEX IF NOT EXISTS reviews_movie_id_idx ON reviews (movie_id);
+
+/* Aggregates are kept up to date by RatingModel rather than calculated on */
+/* every read, rating_total lets us move the average without rescanning ratings */
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_total BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS average_rating NUMERIC(4, 2) NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS movies_average_rating_idx ON movies (average_rating);
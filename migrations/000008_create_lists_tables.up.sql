@@ -0,0 +1,28 @@
+/* share_slug is only set once a list has been made public and is kept if */
+/* the list goes private again, so old share links work once it is re-published */
+CREATE TABLE IF NOT EXISTS lists (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    public BOOLEAN NOT NULL DEFAULT false,
+    share_slug TEXT UNIQUE,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+/* The position constraint is deferred so reordering can shuffle items */
+/* around inside a transaction and only has to be unique at commit */
+CREATE TABLE IF NOT EXISTS list_items (
+    list_id BIGINT NOT NULL REFERENCES lists ON DELETE CASCADE,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    position INTEGER NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    watched_on DATE,
+    PRIMARY KEY (list_id, movie_id),
+    CONSTRAINT list_items_position_key UNIQUE (list_id, position) DEFERRABLE INITIALLY DEFERRED,
+    CONSTRAINT list_items_position_check CHECK (position >= 1)
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);
@@ -0,0 +1,2 @@
+/* Movies keep their canonical slugs, the original spellings are not restored */
+DROP TABLE IF EXISTS genres;
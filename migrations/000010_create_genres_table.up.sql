@@ -0,0 +1,89 @@
+/* aliases hold lookup keys, the lower cased name with everything but letters */
+/* and digits stripped, so "Sci Fi", "sci-fi" and "SciFi" all match "scifi" */
+CREATE TABLE IF NOT EXISTS genres (
+    id BIGSERIAL PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT genres_slug_check CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$')
+);
+
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING gin(aliases);
+
+INSERT INTO genres (slug, name, aliases) VALUES
+    ('action', 'Action', '{}'),
+    ('adventure', 'Adventure', '{}'),
+    ('animation', 'Animation', '{animated,cartoon}'),
+    ('biography', 'Biography', '{biopic,biographical}'),
+    ('comedy', 'Comedy', '{comedies,funny}'),
+    ('crime', 'Crime', '{}'),
+    ('documentary', 'Documentary', '{doc,documentaries}'),
+    ('drama', 'Drama', '{dramas}'),
+    ('family', 'Family', '{kids,children}'),
+    ('fantasy', 'Fantasy', '{}'),
+    ('history', 'History', '{historical}'),
+    ('horror', 'Horror', '{}'),
+    ('music', 'Music', '{}'),
+    ('musical', 'Musical', '{musicals}'),
+    ('mystery', 'Mystery', '{}'),
+    ('romance', 'Romance', '{romantic}'),
+    ('science-fiction', 'Science Fiction', '{scifi,sf,scifiction}'),
+    ('thriller', 'Thriller', '{thrillers}'),
+    ('war', 'War', '{}'),
+    ('western', 'Western', '{westerns}')
+ON CONFLICT (slug) DO NOTHING;
+
+/* Any genre already used by a movie that doesn't match the seeded taxonomy */
+/* becomes a genre of its own so no data is lost. Genres are matched on the */
+/* same key as data.GenreKey, letters and digits in any script. Slugs can only */
+/* hold a-z and 0-9 so genres with other letters get a suffix from their key */
+/* to keep them apart, e.g. "Ciencia ficción" becomes "ciencia-ficci-n-" and */
+/* eight hex digits */
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (key)
+    CASE WHEN key ~ '^[a-z0-9]+$' THEN slug ELSE trim(BOTH '-' FROM slug || '-' || left(md5(key), 8)) END,
+    initcap(trim(g))
+FROM (
+    SELECT g,
+        regexp_replace(lower(g), '[^[:alnum:]]+', '', 'g') AS key,
+        trim(BOTH '-' FROM regexp_replace(lower(g), '[^a-z0-9]+', '-', 'g')) AS slug
+    FROM movies, unnest(movies.genres) AS g
+) AS used
+WHERE key <> ''
+AND NOT EXISTS (
+    SELECT 1 FROM genres
+    WHERE used.key = regexp_replace(lower(genres.slug), '[^[:alnum:]]+', '', 'g')
+    OR used.key = regexp_replace(lower(genres.name), '[^[:alnum:]]+', '', 'g')
+    OR used.key = ANY(genres.aliases)
+)
+ORDER BY key, g
+ON CONFLICT (slug) DO NOTHING;
+
+/* Rewrite every movie's genres to canonical slugs, keeping the original order */
+/* and dropping duplicates created when two spellings map to the same genre. */
+/* Movies already in canonical form are left alone so their version stays put */
+UPDATE movies
+SET genres = normalised.genres,
+    version = movies.version + 1
+FROM (
+    SELECT movies.id, ARRAY(
+        SELECT canonical.slug
+        FROM unnest(movies.genres) WITH ORDINALITY AS t(g, i)
+        CROSS JOIN LATERAL (
+            SELECT genres.slug
+            FROM genres
+            WHERE regexp_replace(lower(t.g), '[^[:alnum:]]+', '', 'g') = regexp_replace(lower(genres.slug), '[^[:alnum:]]+', '', 'g')
+            OR regexp_replace(lower(t.g), '[^[:alnum:]]+', '', 'g') = regexp_replace(lower(genres.name), '[^[:alnum:]]+', '', 'g')
+            OR regexp_replace(lower(t.g), '[^[:alnum:]]+', '', 'g') = ANY(genres.aliases)
+            ORDER BY genres.id
+            LIMIT 1
+        ) AS canonical
+        GROUP BY canonical.slug
+        ORDER BY MIN(t.i)
+    ) AS genres
+    FROM movies
+) AS normalised
+WHERE movies.id = normalised.id
+AND movies.genres IS DISTINCT FROM normalised.genres;
@@ -0,0 +1,9 @@
+/* A movie has at most one id per provider and an id can only belong to one movie */
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, provider),
+    CONSTRAINT movie_external_ids_provider_external_id_key UNIQUE (provider, external_id)
+);
@@ -0,0 +1,4 @@
+/* poster_key is the blob store prefix the images live under, poster_urls */
+/* maps each size ("original", "w185"...) to its public URL */
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS poster_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS poster_urls JSONB;
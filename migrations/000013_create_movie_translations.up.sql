@@ -0,0 +1,15 @@
+/* search_config is the Postgres text search configuration matching the */
+/* language (english, french...) so stemming works for translated titles */
+CREATE TABLE IF NOT EXISTS movie_translations (
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    title TEXT NOT NULL,
+    overview TEXT NOT NULL DEFAULT '',
+    search_config regconfig NOT NULL DEFAULT 'simple',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (movie_id, language)
+);
+
+CREATE INDEX IF NOT EXISTS movie_translations_title_idx ON movie_translations
+USING gin(to_tsvector(search_config, title));
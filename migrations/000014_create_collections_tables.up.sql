@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS collections (
+    id BIGSERIAL PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name TEXT NOT NULL UNIQUE,
+    overview TEXT NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+/* Same deferred position constraint as list_items so members can be */
+/* reordered inside a transaction */
+CREATE TABLE IF NOT EXISTS collection_movies (
+    collection_id BIGINT NOT NULL REFERENCES collections ON DELETE CASCADE,
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, movie_id),
+    CONSTRAINT collection_movies_position_key UNIQUE (collection_id, position) DEFERRABLE INITIALLY DEFERRED,
+    CONSTRAINT collection_movies_position_check CHECK (position >= 1)
+);
+
+CREATE INDEX IF NOT EXISTS collection_movies_movie_id_idx ON collection_movies (movie_id);
+
+INSERT INTO permissions (code)
+VALUES ('collections:write')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS movie_similarity_refreshes;
+DROP TABLE IF EXISTS movie_similarities;
+DROP FUNCTION IF EXISTS array_jaccard(anyarray, anyarray);
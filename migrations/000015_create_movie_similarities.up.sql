@@ -0,0 +1,30 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+/* Size of the intersection over the size of the union, 0 when both are empty */
+CREATE OR REPLACE FUNCTION array_jaccard(a anyarray, b anyarray) RETURNS double precision
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE
+        WHEN cardinality(a) = 0 AND cardinality(b) = 0 THEN 0
+        ELSE (SELECT count(*) FROM (SELECT unnest(a) INTERSECT SELECT unnest(b)) AS i)::double precision
+            / (SELECT count(*) FROM (SELECT unnest(a) UNION SELECT unnest(b)) AS u)
+    END
+$$;
+
+/* Filled in by the similarity refresh job, only the best matches of each */
+/* movie are kept */
+CREATE TABLE IF NOT EXISTS movie_similarities (
+    movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    similar_movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, similar_movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_similarities_score_idx ON movie_similarities (movie_id, score DESC);
+
+/* Every movie the last refresh scored, so a movie with no matches can be told */
+/* apart from one added since */
+CREATE TABLE IF NOT EXISTS movie_similarity_refreshes (
+    movie_id BIGINT PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+    refreshed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
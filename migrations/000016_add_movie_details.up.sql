@@ -0,0 +1,21 @@
+/* release_dates is an array of {"country", "date", "certification"} objects, */
+/* one per country, see data.ReleaseDates */
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS overview TEXT NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tagline TEXT NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS original_language TEXT NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'released';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS release_dates JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE movies
+   ADD CONSTRAINT movies_status_check CHECK (status IN ('announced', 'released'));
+
+/* Announced movies can have a year in the future */
+ALTER TABLE movies
+   DROP CONSTRAINT IF EXISTS movies_year_check;
+
+ALTER TABLE movies
+   ADD CONSTRAINT movies_year_check CHECK (year >= 1888 AND (year <= date_part('year', NOW()) OR status = 'announced'));
+
+CREATE INDEX IF NOT EXISTS movies_status_idx ON movies (status);
+CREATE INDEX IF NOT EXISTS movies_original_language_idx ON movies (original_language);
+CREATE INDEX IF NOT EXISTS movies_release_dates_idx ON movies USING GIN (release_dates jsonb_path_ops);
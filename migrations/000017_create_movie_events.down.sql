@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS movies_update_event ON movies;
+DROP TRIGGER IF EXISTS movies_insert_delete_event ON movies;
+DROP FUNCTION IF EXISTS record_movie_event();
+DROP TABLE IF EXISTS movie_events;
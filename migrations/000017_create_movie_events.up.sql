@@ -0,0 +1,51 @@
+/* One row per change to a movie, written by the trigger below so changes */
+/* made outside the API are seen too. The ids are the SSE event ids that */
+/* clients resume from, the API prunes rows once they're old enough */
+CREATE TABLE IF NOT EXISTS movie_events (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL,
+    type text NOT NULL CHECK (type IN ('created', 'updated', 'deleted')),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS movie_events_created_at_idx ON movie_events (created_at);
+
+/* The notification is only delivered once the transaction commits, its */
+/* payload is the row as JSON so listeners don't have to read it back */
+CREATE OR REPLACE FUNCTION record_movie_event() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    event movie_events;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO movie_events (movie_id, type) VALUES (NEW.id, 'created') RETURNING * INTO event;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO movie_events (movie_id, type) VALUES (NEW.id, 'updated') RETURNING * INTO event;
+    ELSE
+        INSERT INTO movie_events (movie_id, type) VALUES (OLD.id, 'deleted') RETURNING * INTO event;
+    END IF;
+
+    PERFORM pg_notify('movie_events', json_build_object('id', event.id, 'movie_id', event.movie_id, 'type', event.type)::text);
+
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS movies_insert_delete_event ON movies;
+CREATE TRIGGER movies_insert_delete_event
+    AFTER INSERT OR DELETE ON movies
+    FOR EACH ROW EXECUTE FUNCTION record_movie_event();
+
+/* Only the columns people edit count as a change. The rating columns are */
+/* updated with every rating, which would flood clients, and version alone */
+/* changes when a movie is saved without any edits */
+DROP TRIGGER IF EXISTS movies_update_event ON movies;
+CREATE TRIGGER movies_update_event
+    AFTER UPDATE ON movies
+    FOR EACH ROW WHEN (
+        (OLD.title, OLD.year, OLD.runtime, OLD.genres, OLD.overview, OLD.tagline, OLD.original_language,
+            OLD.status, OLD.release_dates, OLD.poster_key, OLD.poster_urls)
+        IS DISTINCT FROM
+        (NEW.title, NEW.year, NEW.runtime, NEW.genres, NEW.overview, NEW.tagline, NEW.original_language,
+            NEW.status, NEW.release_dates, NEW.poster_key, NEW.poster_urls)
+    ) EXECUTE FUNCTION record_movie_event();
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient starts a server that answers every request with h and
+// returns a client for it
+func newTestClient(t *testing.T, h http.HandlerFunc, options ...Option) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// writeProblem answers the way the API does when a client accepts problem
+// details
+func writeProblem(w http.ResponseWriter, status int, code, detail, errors string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	if errors == "" {
+		fmt.Fprintf(w, `{"status":%d,"code":%q,"detail":%q}`, status, code, detail)
+		return
+	}
+
+	fmt.Fprintf(w, `{"status":%d,"code":%q,"detail":%q,"errors":%s}`, status, code, detail, errors)
+}
+
+func TestBearerToken(t *testing.T) {
+	var auth atomic.Value
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/v1/tokens/authentication":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"authentication_token":{"token":"NEWTOKEN","expiry":"2030-01-01T00:00:00Z"}}`)
+		default:
+			fmt.Fprint(w, `{"movie":{"id":1,"title":"Moana","runtime":"107 mins","version":1}}`)
+		}
+	}, WithToken("OLDTOKEN"))
+
+	movie, err := c.GetMovie(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Title != "Moana" || movie.Runtime != 107 {
+		t.Errorf("got movie %+v", movie)
+	}
+
+	if got := auth.Load(); got != "Bearer OLDTOKEN" {
+		t.Errorf("got Authorization %q, want %q", got, "Bearer OLDTOKEN")
+	}
+
+	_, err = c.Authenticate(context.Background(), "alice@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.GetMovie(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := auth.Load(); got != "Bearer NEWTOKEN" {
+		t.Errorf("got Authorization %q after Authenticate, want %q", got, "Bearer NEWTOKEN")
+	}
+
+	c.SetToken("")
+
+	_, err = c.GetMovie(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := auth.Load(); got != "" {
+		t.Errorf("got Authorization %q with no token", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+
+	// The backoff is far longer than the test's deadline, so the retry
+	// only happens in time if Retry-After is used instead
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			writeProblem(w, http.StatusTooManyRequests, CodeRateLimitExceeded, "rate limit exceeded", "")
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"movie":{"id":2,"title":"Up","version":1}}`)
+	}, WithRetries(3, time.Hour, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// POST isn't idempotent but a 429 means the API never acted on it
+	movie, err := c.CreateMovie(ctx, MovieInput{Title: Ptr("Up")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.ID != 2 {
+		t.Errorf("got movie %+v", movie)
+	}
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestRetryAfterGivesUp(t *testing.T) {
+	var requests atomic.Int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "0")
+		writeProblem(w, http.StatusTooManyRequests, CodeRateLimitExceeded, "rate limit exceeded", "")
+	}, WithRetries(2, time.Hour, time.Hour))
+
+	_, err := c.GetMovie(context.Background(), 1)
+	if !errors.Is(err, ErrRateLimitExceeded) {
+		t.Fatalf("got error %v, want ErrRateLimitExceeded", err)
+	}
+
+	if got := requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, http.StatusUnprocessableEntity, CodeFailedValidation, "the request failed validation", `{
+			"title": [{"code": "required", "message": "must be provided"}],
+			"genres[1]": [{"code": "unknown_genre", "message": "is not a known genre", "params": {"genre": "westerns"}}]
+		}`)
+	})
+
+	_, err := c.CreateMovie(context.Background(), MovieInput{Genres: []string{"drama", "westerns"}})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v, want a *ValidationError", err)
+	}
+
+	if verr.Err.StatusCode != http.StatusUnprocessableEntity || verr.Err.Code != CodeFailedValidation {
+		t.Errorf("got %+v", verr.Err)
+	}
+
+	title := verr.Field("title")
+	if len(title) != 1 || title[0].Code != "required" || title[0].Message != "must be provided" {
+		t.Errorf("got title errors %+v", title)
+	}
+
+	genre := verr.Field("genres[1]")
+	if len(genre) != 1 || genre[0].Code != "unknown_genre" || genre[0].Params["genre"] != "westerns" {
+		t.Errorf("got genres[1] errors %+v", genre)
+	}
+
+	if verr.Field("year") != nil {
+		t.Errorf("got year errors %+v", verr.Field("year"))
+	}
+
+	want := "greenlight: 422 failed_validation: the request failed validation (genres[1]: is not a known genre; title: must be provided)"
+	if err.Error() != want {
+		t.Errorf("got message %q, want %q", err.Error(), want)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   string
+		call   func(c *Client) error
+		want   error
+	}{
+		{
+			name:   "not found",
+			status: http.StatusNotFound,
+			code:   CodeNotFound,
+			call: func(c *Client) error {
+				_, err := c.GetMovie(context.Background(), 404)
+				return err
+			},
+			want: ErrNotFound,
+		},
+		{
+			name:   "edit conflict",
+			status: http.StatusConflict,
+			code:   CodeEditConflict,
+			call: func(c *Client) error {
+				_, err := c.UpdateMovie(context.Background(), 1, MovieInput{Title: Ptr("Moana")})
+				return err
+			},
+			want: ErrEditConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeProblem(w, tt.status, tt.code, "something went wrong", "")
+			})
+
+			err := tt.call(c)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != "something went wrong" {
+				t.Errorf("got %+v", apiErr)
+			}
+
+			for _, other := range []error{ErrNotFound, ErrEditConflict, ErrRateLimitExceeded} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("error also matches %v", other)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// readError turns an error response into an *Error or a *ValidationError.
+// Responses that aren't from the API, e.g. a proxy's 502 page, still come
+// back as an *Error with the status text as the message
+func readError(res *http.Response) error {
+	var problem struct {
+		Code   string                  `json:"code"`
+		Detail string                  `json:"detail"`
+		Errors map[string][]FieldError `json:"errors"`
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+
+	apiErr := &Error{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+
+	if err := json.Unmarshal(body, &problem); err != nil {
+		return apiErr
+	}
+
+	apiErr.Code = problem.Code
+	if problem.Detail != "" {
+		apiErr.Message = problem.Detail
+	}
+
+	if problem.Errors != nil {
+		return &ValidationError{Err: apiErr, Fields: problem.Errors}
+	}
+
+	return apiErr
+}
+
+// The codes the API sends in its error responses
+const (
+	CodeBadRequest                 = "bad_request"
+	CodeNotFound                   = "not_found"
+	CodeEditConflict               = "edit_conflict"
+	CodeFailedValidation           = "failed_validation"
+	CodeRateLimitExceeded          = "rate_limit_exceeded"
+	CodeInvalidCredentials         = "invalid_credentials"
+	CodeInvalidAuthenticationToken = "invalid_authentication_token"
+	CodeAuthenticationRequired     = "authentication_required"
+	CodeNotPermitted               = "not_permitted"
+	CodeServerError                = "server_error"
+)
+
+var (
+	ErrNotFound          = &Error{Code: CodeNotFound}
+	ErrEditConflict      = &Error{Code: CodeEditConflict}
+	ErrRateLimitExceeded = &Error{Code: CodeRateLimitExceeded}
+)
+
+// Error is an error response from the API. Message is in the language of
+// the API's default locale
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("greenlight: %d %s", e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf("greenlight: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is makes errors.Is(err, ErrNotFound) and the like match on the code
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if !errors.As(target, &t) {
+		return false
+	}
+
+	return t.Code != "" && t.Code == e.Code
+}
+
+// FieldError is one of the reasons a field failed validation. Code is
+// stable, e.g. "required" or "max_items", Message is for people
+type FieldError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// ValidationError is a 422 response. Fields is keyed by the field's path in
+// the request, e.g. "title", "genres[2]" or "release_dates[0].country"
+type ValidationError struct {
+	Err    *Error
+	Fields map[string][]FieldError
+}
+
+func (e *ValidationError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+
+	// Sort so the message is the same every time
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, fe := range e.Fields[key] {
+			parts = append(parts, key+": "+fe.Message)
+		}
+	}
+
+	return e.Err.Error() + " (" + strings.Join(parts, "; ") + ")"
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Field returns the errors for key, nil when the field was fine
+func (e *ValidationError) Field(key string) []FieldError {
+	return e.Fields[key]
+}
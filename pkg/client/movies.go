@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Runtime is a movie's length in minutes. The API writes it as "102 mins"
+type Runtime int32
+
+func (r Runtime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%d mins", r))
+}
+
+func (r *Runtime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("client: runtime must be a string: %w", err)
+	}
+
+	mins, err := strconv.ParseInt(strings.TrimSuffix(s, " mins"), 10, 32)
+	if err != nil {
+		return fmt.Errorf("client: invalid runtime %q", s)
+	}
+
+	*r = Runtime(mins)
+	return nil
+}
+
+// ReleaseDate is when a movie came out in a country. Date is YYYY-MM-DD
+type ReleaseDate struct {
+	Country       string `json:"country"`
+	Date          string `json:"date"`
+	Certification string `json:"certification,omitempty"`
+}
+
+type Movie struct {
+	ID               int64             `json:"id"`
+	Title            string            `json:"title"`
+	Year             int32             `json:"year,omitempty"`
+	Runtime          Runtime           `json:"runtime,omitempty"`
+	Genres           []string          `json:"genres,omitempty"`
+	Version          int32             `json:"version"`
+	AverageRating    float64           `json:"average_rating"`
+	RatingCount      int32             `json:"rating_count"`
+	ExternalIDs      map[string]string `json:"external_ids,omitempty"`
+	Poster           map[string]string `json:"poster,omitempty"`
+	Overview         string            `json:"overview,omitempty"`
+	Tagline          string            `json:"tagline,omitempty"`
+	OriginalLanguage string            `json:"original_language,omitempty"`
+	Status           string            `json:"status"`
+	ReleaseDates     []ReleaseDate     `json:"release_dates,omitempty"`
+	// OriginalTitle and Language are only set when Title was translated
+	OriginalTitle string            `json:"original_title,omitempty"`
+	Language      string            `json:"language,omitempty"`
+	Links         map[string]string `json:"links,omitempty"`
+}
+
+// MovieInput is the body of CreateMovie and UpdateMovie. Nil fields are
+// left out, so an update only changes the fields that are set
+type MovieInput struct {
+	Title    *string  `json:"title,omitempty"`
+	Year     *int32   `json:"year,omitempty"`
+	Runtime  *Runtime `json:"runtime,omitempty"`
+	Genres   []string `json:"genres,omitempty"`
+	Overview *string  `json:"overview,omitempty"`
+	Tagline  *string  `json:"tagline,omitempty"`
+	// An empty string clears the original language
+	OriginalLanguage *string `json:"original_language,omitempty"`
+	Status           *string `json:"status,omitempty"`
+	// A non-nil empty slice clears the release dates
+	ReleaseDates *[]ReleaseDate `json:"release_dates,omitempty"`
+}
+
+// Ptr returns a pointer to v, for filling in MovieInput
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Metadata describes a page of results. It's the zero value when nothing
+// matched
+type Metadata struct {
+	CurrentPage  int               `json:"current_page,omitempty"`
+	PageSize     int               `json:"page_size,omitempty"`
+	FirstPage    int               `json:"first_page,omitempty"`
+	LastPage     int               `json:"last_page,omitempty"`
+	TotalRecords int               `json:"total_records,omitempty"`
+	Links        map[string]string `json:"links,omitempty"`
+}
+
+// ListMoviesOptions filters and pages ListMovies. Zero values are left to
+// the API's defaults
+type ListMoviesOptions struct {
+	Title            string
+	Genres           []string
+	PersonID         int64
+	CollectionID     int64
+	Status           string
+	OriginalLanguage string
+	Country          string
+	Certification    string
+	// ReleasedFrom and ReleasedTo are YYYY-MM-DD
+	ReleasedFrom string
+	ReleasedTo   string
+	Page         int
+	PageSize     int
+	// One of id, title, year, runtime or rating, with a leading - for
+	// descending order
+	Sort string
+}
+
+func (o ListMoviesOptions) values() url.Values {
+	qs := url.Values{}
+
+	set := func(key, value string) {
+		if value != "" {
+			qs.Set(key, value)
+		}
+	}
+
+	setInt := func(key string, value int64) {
+		if value != 0 {
+			qs.Set(key, strconv.FormatInt(value, 10))
+		}
+	}
+
+	set("title", o.Title)
+	set("genres", strings.Join(o.Genres, ","))
+	setInt("person_id", o.PersonID)
+	setInt("collection_id", o.CollectionID)
+	set("status", o.Status)
+	set("original_language", o.OriginalLanguage)
+	set("country", o.Country)
+	set("certification", o.Certification)
+	set("released_from", o.ReleasedFrom)
+	set("released_to", o.ReleasedTo)
+	setInt("page", int64(o.Page))
+	setInt("page_size", int64(o.PageSize))
+	set("sort", o.Sort)
+
+	return qs
+}
+
+func moviePath(id int64) string {
+	return "/v1/movies/" + strconv.FormatInt(id, 10)
+}
+
+func (c *Client) ListMovies(ctx context.Context, options ListMoviesOptions) ([]Movie, Metadata, error) {
+	var out struct {
+		Movies   []Movie  `json:"movies"`
+		Metadata Metadata `json:"metadata"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/v1/movies", options.values(), nil, &out)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return out.Movies, out.Metadata, nil
+}
+
+func (c *Client) GetMovie(ctx context.Context, id int64) (*Movie, error) {
+	var out struct {
+		Movie *Movie `json:"movie"`
+	}
+
+	err := c.do(ctx, http.MethodGet, moviePath(id), nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Movie, nil
+}
+
+// CreateMovie needs at least a title, year, runtime and genres. Like
+// UpdateMovie and DeleteMovie it needs a token for a user with the
+// movies:write permission
+func (c *Client) CreateMovie(ctx context.Context, input MovieInput) (*Movie, error) {
+	var out struct {
+		Movie *Movie `json:"movie"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/v1/movies", nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Movie, nil
+}
+
+// UpdateMovie changes the fields set in input. It fails with an error
+// matching ErrEditConflict if someone else updated the movie at the same
+// time
+func (c *Client) UpdateMovie(ctx context.Context, id int64, input MovieInput) (*Movie, error) {
+	var out struct {
+		Movie *Movie `json:"movie"`
+	}
+
+	err := c.do(ctx, http.MethodPatch, moviePath(id), nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Movie, nil
+}
+
+func (c *Client) DeleteMovie(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, moviePath(id), nil, nil, nil)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type User struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Activated bool      `json:"activated"`
+}
+
+type Token struct {
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// RegisterUser creates an account. It can't be used to authenticate until
+// it has been activated
+func (c *Client) RegisterUser(ctx context.Context, name, email, password string) (*User, error) {
+	in := map[string]string{"name": name, "email": email, "password": password}
+
+	var out struct {
+		User *User `json:"user"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/v1/users", nil, in, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.User, nil
+}
+
+// Authenticate exchanges an email and password for a token, which the
+// client sends with every request from then on
+func (c *Client) Authenticate(ctx context.Context, email, password string) (*Token, error) {
+	in := map[string]string{"email": email, "password": password}
+
+	var out struct {
+		Token *Token `json:"authentication_token"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/v1/tokens/authentication", nil, in, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetToken(out.Token.Token)
+
+	return out.Token, nil
+}
@@ -0,0 +1,5 @@
+// Package moviesv1 holds the code generated from movies.proto for the gRPC
+// movie service.
+package moviesv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative ../../movies/v1/movies.proto